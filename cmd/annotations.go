@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// annotationPrefix is the prefix of the standard OCI annotations, which
+// can be given and are shown by their short name
+const annotationPrefix = "org.opencontainers.image."
+
+// parseAnnotations parses key=value annotations. Keys without a dot are
+// short names of standard annotations, e.g. "licenses" for
+// org.opencontainers.image.licenses.
+func parseAnnotations(values []string) (map[string]string, error) {
+	annotations := make(map[string]string)
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid annotation %q, expected key=value", value)
+		}
+		if !strings.Contains(key, ".") {
+			key = annotationPrefix + key
+		}
+		annotations[key] = val
+	}
+	return annotations, nil
+}
+
+// gitAnnotations returns the creation time, revision and source of the git
+// checkout in the current directory. Nothing is returned outside of a
+// checkout or when git is not installed.
+func gitAnnotations() map[string]string {
+	annotations := make(map[string]string)
+
+	if revision, err := git("rev-parse", "HEAD"); err == nil {
+		annotations[ocispec.AnnotationRevision] = revision
+	}
+	if created, err := git("log", "-1", "--format=%cI"); err == nil {
+		annotations[ocispec.AnnotationCreated] = created
+	}
+	if remote, err := git("remote", "get-url", "origin"); err == nil {
+		if source := gitSourceURL(remote); source != "" {
+			annotations[ocispec.AnnotationSource] = source
+		}
+	}
+
+	return annotations
+}
+
+func git(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitSourceURL turns a git remote into a browsable https URL, dropping any
+// credentials. scp-like remotes such as git@github.com:org/repo.git are
+// converted.
+func gitSourceURL(remote string) string {
+	if !strings.Contains(remote, "://") {
+		userHost, repoPath, ok := strings.Cut(remote, ":")
+		if !ok {
+			return ""
+		}
+		_, host, _ := strings.Cut(userHost, "@")
+		if host == "" {
+			host = userHost
+		}
+		remote = "https://" + host + "/" + repoPath
+	}
+
+	u, err := url.Parse(remote)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	return "https://" + u.Hostname() + "/" + strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+}
+
+// printAnnotationLines prints annotations sorted by key, with the standard
+// ones by their short name
+func printAnnotationLines(indent string, annotations map[string]string) {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf("%s%s: %s\n", indent, strings.TrimPrefix(key, annotationPrefix), annotations[key])
+	}
+}
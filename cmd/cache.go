@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aep/bolter/pkg/bolter"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local binary cache",
+	Long:  `Inspect and clean up binaries cached locally by pull and run operations.`,
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached binaries",
+	Long: `Remove cached binaries that are old, outdated or exceed a size limit.
+Entries are evicted least recently used first.
+
+Example:
+  bolter cache prune --older-than 30d
+  bolter cache prune --keep-last 3 --max-size 2GB`,
+	Args: cobra.NoArgs,
+	Run:  runCachePrune,
+}
+
+var cacheRmCmd = &cobra.Command{
+	Use:   "rm [repository[:tag]]...",
+	Short: "Remove a reference from the cache",
+	Long: `Remove all cached platforms of a reference from the cache.
+Without a tag, every cached tag of the repository is removed.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runCacheRm,
+}
+
+var (
+	cachePruneOlderThan string
+	cachePruneKeepLast  int
+	cachePruneMaxSize   string
+	cachePruneDryRun    bool
+)
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	cacheCmd.AddCommand(cacheRmCmd)
+	cachePruneCmd.Flags().StringVar(&cachePruneOlderThan, "older-than", "", "Remove entries not used within this duration (e.g., 12h, 30d)")
+	cachePruneCmd.Flags().IntVar(&cachePruneKeepLast, "keep-last", 0, "Keep only the N most recently used tags per repository")
+	cachePruneCmd.Flags().StringVar(&cachePruneMaxSize, "max-size", "", "Evict least recently used entries until the cache fits (e.g., 500MB, 2GB)")
+	cachePruneCmd.Flags().BoolVar(&cachePruneDryRun, "dry-run", false, "Show what would be removed without removing anything")
+}
+
+func runCachePrune(cmd *cobra.Command, args []string) {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	var opts bolter.PruneOptions
+	var err error
+
+	if cachePruneOlderThan != "" {
+		opts.OlderThan, err = parseAge(cachePruneOlderThan)
+		if err != nil {
+			exitWithError("invalid --older-than", err)
+		}
+	}
+	if cachePruneMaxSize != "" {
+		opts.MaxSize, err = parseSize(cachePruneMaxSize)
+		if err != nil {
+			exitWithError("invalid --max-size", err)
+		}
+	}
+	if cachePruneKeepLast < 0 {
+		exitWithError("--keep-last must not be negative", nil)
+	}
+	opts.KeepLast = cachePruneKeepLast
+	opts.DryRun = cachePruneDryRun
+
+	if opts.OlderThan == 0 && opts.KeepLast == 0 && opts.MaxSize == 0 {
+		exitWithError("nothing to prune, use --older-than, --keep-last or --max-size", nil)
+	}
+
+	cache, err := bolter.NewCache("")
+	if err != nil {
+		exitWithError("failed to open cache", err)
+	}
+
+	result, err := cache.Prune(opts)
+	if err != nil {
+		exitWithError("failed to prune cache", err)
+	}
+
+	action := "Removed"
+	if opts.DryRun {
+		action = "Would remove"
+	}
+
+	for _, entry := range result.Removed {
+		if verbose || opts.DryRun {
+			fmt.Printf("%s %s (%s)\n", action, entry.Reference, entry.Platform())
+		}
+	}
+	fmt.Printf("%s %d entries, freeing %s\n", action, len(result.Removed), formatSize(result.Freed))
+}
+
+func runCacheRm(cmd *cobra.Command, args []string) {
+	cache, err := bolter.NewCache("")
+	if err != nil {
+		exitWithError("failed to open cache", err)
+	}
+
+	for _, ref := range args {
+		removed, err := cache.Remove(ref)
+		if err != nil {
+			exitWithError(fmt.Sprintf("failed to remove %s", ref), err)
+		}
+		for _, entry := range removed {
+			fmt.Printf("Removed %s (%s)\n", entry.Reference, entry.Platform())
+		}
+	}
+}
+
+// parseAge parses a duration, additionally accepting a "d" suffix for days
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseSize parses a size such as "512", "500MB" or "2GiB" into bytes
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+		{"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+		{"B", 1},
+	}
+
+	value := strings.TrimSpace(s)
+	multiplier := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(strings.ToUpper(value), strings.ToUpper(unit.suffix)) {
+			value = strings.TrimSpace(value[:len(value)-len(unit.suffix)])
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return int64(n * multiplier), nil
+}
@@ -1,13 +1,10 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
 	"time"
 
+	"github.com/aep/bolter/pkg/bolter"
 	"github.com/spf13/cobra"
 )
 
@@ -22,73 +19,10 @@ func init() {
 	rootCmd.AddCommand(cachedCmd)
 }
 
-type cacheMetadata struct {
-	Registry     string    `json:"registry"`
-	Repository   string    `json:"repository"`
-	Tag          string    `json:"tag"`
-	OS           string    `json:"os"`
-	Architecture string    `json:"architecture"`
-	Digest       string    `json:"digest"`
-	Size         int64     `json:"size"`
-	CachedAt     time.Time `json:"cached_at"`
-}
-
 func runCached(cmd *cobra.Command, args []string) {
 	verbose, _ := cmd.Flags().GetBool("verbose")
 
-	cacheDir, err := getCacheDir()
-	if err != nil {
-		exitWithError("failed to get cache directory", err)
-	}
-
-	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
-		fmt.Println("No cached binaries found")
-		return
-	}
-
-	var entries []cacheEntry
-	err = filepath.Walk(cacheDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Look for metadata files
-		if !info.IsDir() && filepath.Base(path) == "metadata.json" {
-			data, err := os.ReadFile(path)
-			if err != nil {
-				if verbose {
-					fmt.Printf("Warning: failed to read %s: %v\n", path, err)
-				}
-				return nil
-			}
-
-			var meta cacheMetadata
-			if err := json.Unmarshal(data, &meta); err != nil {
-				if verbose {
-					fmt.Printf("Warning: failed to parse %s: %v\n", path, err)
-				}
-				return nil
-			}
-
-			binaryPath := filepath.Join(filepath.Dir(path), fmt.Sprintf("%s-%s", meta.OS, meta.Architecture))
-			binaryInfo, err := os.Stat(binaryPath)
-			if err != nil {
-				if verbose {
-					fmt.Printf("Warning: binary not found for metadata %s\n", path)
-				}
-				return nil
-			}
-
-			entries = append(entries, cacheEntry{
-				metadata:   meta,
-				binaryPath: binaryPath,
-				binarySize: binaryInfo.Size(),
-			})
-		}
-
-		return nil
-	})
-
+	entries, err := bolter.ListCached("")
 	if err != nil {
 		exitWithError("failed to scan cache directory", err)
 	}
@@ -100,27 +34,19 @@ func runCached(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("Cached binaries (%d):\n\n", len(entries))
 	for _, entry := range entries {
-		ref := fmt.Sprintf("%s/%s:%s", entry.metadata.Registry, entry.metadata.Repository, entry.metadata.Tag)
-		platform := fmt.Sprintf("%s/%s", entry.metadata.OS, entry.metadata.Architecture)
-
-		fmt.Printf("  %s\n", ref)
-		fmt.Printf("    Platform: %s\n", platform)
-		fmt.Printf("    Digest: %s\n", entry.metadata.Digest)
-		fmt.Printf("    Size: %s\n", formatSize(entry.binarySize))
-		fmt.Printf("    Cached: %s\n", formatTime(entry.metadata.CachedAt))
+		fmt.Printf("  %s\n", entry.Reference)
+		fmt.Printf("    Platform: %s\n", entry.Platform())
+		fmt.Printf("    Digest: %s\n", entry.Digest)
+		fmt.Printf("    Size: %s\n", formatSize(entry.Size))
+		fmt.Printf("    Cached: %s\n", formatTime(entry.CachedAt))
 		if verbose {
-			fmt.Printf("    Path: %s\n", entry.binaryPath)
+			fmt.Printf("    Layer: %s (%s)\n", entry.LayerDigest, entry.MediaType)
+			fmt.Printf("    Path: %s\n", entry.Path)
 		}
 		fmt.Println()
 	}
 }
 
-type cacheEntry struct {
-	metadata   cacheMetadata
-	binaryPath string
-	binarySize int64
-}
-
 func formatSize(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
@@ -161,56 +87,3 @@ func formatTime(t time.Time) string {
 		return t.Format("2006-01-02")
 	}
 }
-
-func getCacheDir() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
-	}
-
-	return filepath.Join(homeDir, ".cache", "bolter"), nil
-}
-
-func getCachePathForRef(cacheDir, registry, repository, tag, goos, arch string) string {
-	// Normalize registry name
-	registry = strings.ReplaceAll(registry, ":", "_")
-	repository = strings.ReplaceAll(repository, "/", "_")
-
-	return filepath.Join(cacheDir, registry, repository, tag, fmt.Sprintf("%s-%s", goos, arch))
-}
-
-func getCacheMetadataPath(cacheDir, registry, repository, tag string) string {
-	registry = strings.ReplaceAll(registry, ":", "_")
-	repository = strings.ReplaceAll(repository, "/", "_")
-
-	return filepath.Join(cacheDir, registry, repository, tag, "metadata.json")
-}
-
-func saveCacheMetadata(cacheDir, registry, repository, tag, goos, arch, digest string, size int64) error {
-	registry = strings.ReplaceAll(registry, ":", "_")
-	repository = strings.ReplaceAll(repository, "/", "_")
-
-	metaPath := filepath.Join(cacheDir, registry, repository, tag, "metadata.json")
-
-	meta := cacheMetadata{
-		Registry:     registry,
-		Repository:   repository,
-		Tag:          tag,
-		OS:           goos,
-		Architecture: arch,
-		Digest:       digest,
-		Size:         size,
-		CachedAt:     time.Now(),
-	}
-
-	data, err := json.MarshalIndent(meta, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
-		return err
-	}
-
-	return os.WriteFile(metaPath, data, 0644)
-}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aep/bolter/pkg/bolter"
+	"github.com/spf13/cobra"
+)
+
+var copyCmd = &cobra.Command{
+	Use:     "copy [src-repository:tag|src-repository@range] [dst-repository[:tag]]",
+	Aliases: []string{"cp"},
+	Short:   "Copy an artifact to another repository or registry",
+	Long: `Copy the index, every platform manifest and every binary of an artifact to
+another repository, for example to promote a release candidate. Content is
+copied byte for byte, so digests stay the same. Blobs are mounted instead of
+copied when both repositories are on the same registry.
+
+With --platform, only the given platforms are copied into a new index,
+which has a different digest. If the destination has no tag and no --tag is
+given, the tag of the source is used.
+
+Example:
+  bolter copy staging.example.com/tool:rc3 ghcr.io/org/tool:v1.0 --tag latest
+  bolter copy ghcr.io/org/tool:v1.0 ghcr.io/org/tool-linux --platform linux/amd64 --platform linux/arm64`,
+	Args: cobra.ExactArgs(2),
+	Run:  runCopy,
+}
+
+var (
+	copyUsername  string
+	copyPassword  string
+	copyPlatforms []string
+	copyTags      []string
+	copyPre       bool
+)
+
+func init() {
+	rootCmd.AddCommand(copyCmd)
+	copyCmd.Flags().StringVarP(&copyUsername, "username", "u", "", "Registry username")
+	copyCmd.Flags().StringVarP(&copyPassword, "password", "p", "", "Registry password")
+	copyCmd.Flags().StringArrayVar(&copyPlatforms, "platform", nil, "Only copy this platform (e.g., linux/amd64), can be repeated")
+	copyCmd.Flags().StringArrayVarP(&copyTags, "tag", "t", nil, "Additional tag to apply at the destination, can be repeated")
+	copyCmd.Flags().BoolVar(&copyPre, "pre", false, "Allow version ranges to match pre-release tags")
+}
+
+func runCopy(cmd *cobra.Command, args []string) {
+	src, dst := args[0], args[1]
+
+	insecure, _ := cmd.Flags().GetBool("insecure")
+
+	ctx := context.Background()
+
+	opts := bolter.CopyOptions{
+		Platforms:  copyPlatforms,
+		Tags:       copyTags,
+		Username:   copyUsername,
+		Password:   copyPassword,
+		Insecure:   insecure,
+		Logger:     newLogger(cmd),
+		Prerelease: copyPre,
+	}
+
+	desc, err := bolter.Copy(ctx, src, dst, opts)
+	if err != nil {
+		exitWithError("copy failed", err)
+	}
+
+	fmt.Printf("Successfully copied %s to %s (%s)\n", src, dst, desc.Digest)
+}
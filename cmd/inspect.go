@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/aep/bolter/pkg/bolter"
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [repository:tag|repository@range]",
+	Short: "Show the manifest, annotations and build info of a binary",
+	Long: `Show the index and manifest of an artifact, its annotations and config,
+and the binary for the current or specified platform. For Go binaries the
+embedded build information is shown: Go version, main module, VCS revision
+and dependencies. The binary is downloaded into the cache to read it.
+
+With --cached, the cached copy of the reference is inspected without
+contacting the registry.
+
+--format json prints everything as JSON, any other format is a Go template
+executed on the same data, with a json function:
+
+  bolter inspect ghcr.io/org/tool:v1.2.3 --format '{{.BuildInfo.Revision}}'
+  bolter inspect ghcr.io/org/tool:v1.2.3 --format '{{json .Annotations}}'`,
+	Args: cobra.ExactArgs(1),
+	Run:  runInspect,
+}
+
+var (
+	inspectUsername string
+	inspectPassword string
+	inspectPlatform string
+	inspectPre      bool
+	inspectCached   bool
+	inspectFormat   string
+)
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+	inspectCmd.Flags().StringVarP(&inspectUsername, "username", "u", "", "Registry username")
+	inspectCmd.Flags().StringVarP(&inspectPassword, "password", "p", "", "Registry password")
+	inspectCmd.Flags().StringVar(&inspectPlatform, "platform", "", "Platform to inspect (e.g., linux/amd64 or linux/arm/v7). Defaults to current platform")
+	inspectCmd.Flags().BoolVar(&inspectPre, "pre", false, "Allow version ranges to match pre-release tags")
+	inspectCmd.Flags().BoolVar(&inspectCached, "cached", false, "Inspect the cached copy without contacting the registry")
+	inspectCmd.Flags().StringVarP(&inspectFormat, "format", "f", "", "Output format: json or a Go template")
+}
+
+func runInspect(cmd *cobra.Command, args []string) {
+	insecure, _ := cmd.Flags().GetBool("insecure")
+
+	ctx := context.Background()
+
+	inspection, err := bolter.Inspect(ctx, args[0], bolter.InspectOptions{
+		Platform:   inspectPlatform,
+		Username:   inspectUsername,
+		Password:   inspectPassword,
+		Insecure:   insecure,
+		Logger:     newLogger(cmd),
+		Prerelease: inspectPre,
+		Cached:     inspectCached,
+		Progress:   newProgressRenderer(os.Stderr, "Downloading").Handle,
+	})
+	if err != nil {
+		exitWithError("inspect failed", err)
+	}
+
+	switch inspectFormat {
+	case "":
+		printInspection(inspection)
+	case "json":
+		data, err := json.MarshalIndent(inspection, "", "  ")
+		if err != nil {
+			exitWithError("failed to encode inspection", err)
+		}
+		fmt.Println(string(data))
+	default:
+		tmpl, err := template.New("format").Funcs(template.FuncMap{"json": templateJSON}).Parse(inspectFormat)
+		if err != nil {
+			exitWithError("invalid format", err)
+		}
+		if err := tmpl.Execute(os.Stdout, inspection); err != nil {
+			exitWithError("failed to execute format", err)
+		}
+		fmt.Println()
+	}
+}
+
+// templateJSON is the json function of --format templates
+func templateJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func printInspection(i *bolter.Inspection) {
+	fmt.Printf("Reference: %s\n", i.Reference)
+	fmt.Printf("Digest: %s (%s)\n", i.Digest, i.MediaType)
+	fmt.Printf("Platform: %s\n", i.Platform)
+	fmt.Printf("Manifest: %s\n", i.ManifestDigest)
+	if i.ArtifactType != "" {
+		fmt.Printf("Artifact type: %s\n", i.ArtifactType)
+	}
+	if !i.Created.IsZero() {
+		fmt.Printf("Created: %s\n", formatTime(i.Created))
+	}
+	fmt.Printf("Config: %s (%s, %d bytes)\n", i.Config.Digest, i.Config.MediaType, i.Config.Size)
+	fmt.Printf("Layer: %s (%s, %s)\n", i.Layer.Digest, i.Layer.MediaType, formatSize(i.Layer.Size))
+	if i.Path != "" {
+		fmt.Printf("Path: %s\n", i.Path)
+	}
+
+	printAnnotations("Annotations", i.Annotations)
+	printAnnotations("Layer annotations", i.Layer.Annotations)
+
+	if info := i.BuildInfo; info != nil {
+		fmt.Printf("\nGo build info:\n")
+		fmt.Printf("  Go version: %s\n", info.GoVersion)
+		fmt.Printf("  Path: %s\n", info.Path)
+		fmt.Printf("  Module: %s\n", formatModule(info.Main))
+		if info.Revision != "" {
+			revision := info.Revision
+			if info.RevisionTime != "" {
+				revision += " (" + info.RevisionTime + ")"
+			}
+			if info.Modified {
+				revision += ", modified"
+			}
+			fmt.Printf("  Revision: %s\n", revision)
+		}
+		if len(info.Deps) > 0 {
+			fmt.Printf("  Dependencies (%d):\n", len(info.Deps))
+			for _, dep := range info.Deps {
+				fmt.Printf("    %s\n", formatModule(dep))
+			}
+		}
+	}
+
+	if i.ConfigData != nil {
+		printRawJSON("Config", i.ConfigData)
+	}
+	if i.Index != nil {
+		printRawJSON("Index", i.Index)
+	}
+	printRawJSON("Manifest", i.Manifest)
+}
+
+func printAnnotations(title string, annotations map[string]string) {
+	if len(annotations) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s:\n", title)
+	printAnnotationLines("  ", annotations)
+}
+
+func printRawJSON(title string, data []byte) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "  ", "  "); err != nil {
+		buf.Reset()
+		buf.Write(data)
+	}
+	fmt.Printf("\n%s:\n  %s\n", title, buf.String())
+}
+
+// formatModule formats a module as "path version", followed by its
+// replacement if there is one
+func formatModule(m bolter.GoModule) string {
+	s := m.Path
+	if m.Version != "" {
+		s += " " + m.Version
+	}
+	if m.Replace != nil {
+		s += " => " + formatModule(*m.Replace)
+	}
+	return s
+}
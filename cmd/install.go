@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aep/bolter/pkg/bolter"
+	"github.com/spf13/cobra"
+)
+
+var installCmd = &cobra.Command{
+	Use:   "install [tool]...",
+	Short: "Install the tools pinned in bolter.lock",
+	Long: `Pull exactly the digests pinned in bolter.lock into the cache.
+Fails if the registry content does not match the lockfile.
+
+Example:
+  bolter install
+  bolter install golangci-lint --dir ./bin`,
+	Run: runInstall,
+}
+
+var (
+	installUsername string
+	installPassword string
+	installPlatform string
+	installDir      string
+)
+
+func init() {
+	rootCmd.AddCommand(installCmd)
+	installCmd.Flags().StringVarP(&installUsername, "username", "u", "", "Registry username")
+	installCmd.Flags().StringVarP(&installPassword, "password", "p", "", "Registry password")
+	installCmd.Flags().StringVar(&installPlatform, "platform", "", "Platform to install (e.g., linux/amd64 or linux/arm/v7). Defaults to current platform")
+	installCmd.Flags().StringVar(&installDir, "dir", "", "Also copy binaries into this directory, named after their tool")
+}
+
+func runInstall(cmd *cobra.Command, args []string) {
+	insecure, _ := cmd.Flags().GetBool("insecure")
+
+	lockfile := loadLockfile(loadProject())
+
+	ctx := context.Background()
+
+	installed, err := bolter.Install(ctx, lockfile, bolter.InstallOptions{
+		Platform: installPlatform,
+		Username: installUsername,
+		Password: installPassword,
+		Insecure: insecure,
+		Logger:   newLogger(cmd),
+		Dir:      installDir,
+		Tools:    args,
+	})
+	if err != nil {
+		exitWithError("install failed", err)
+	}
+
+	for _, info := range installed {
+		fmt.Printf("Installed %s (%s) to %s\n", info.Reference, info.Digest, info.Path)
+	}
+}
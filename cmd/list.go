@@ -2,19 +2,15 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 
+	"github.com/aep/bolter/pkg/bolter"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
-	"oras.land/oras-go/v2/registry/remote"
-	"oras.land/oras-go/v2/registry/remote/auth"
-	"oras.land/oras-go/v2/registry/remote/retry"
 )
 
 var listCmd = &cobra.Command{
-	Use:   "list [repository:tag]",
+	Use:   "list [repository:tag|repository@range]",
 	Short: "List available architectures for an artifact",
 	Long:  `List all available architectures for a multi-architecture binary artifact.`,
 	Args:  cobra.ExactArgs(1),
@@ -24,133 +20,77 @@ var listCmd = &cobra.Command{
 var (
 	listUsername string
 	listPassword string
+	listPre      bool
 )
 
 func init() {
 	rootCmd.AddCommand(listCmd)
 	listCmd.Flags().StringVarP(&listUsername, "username", "u", "", "Registry username")
 	listCmd.Flags().StringVarP(&listPassword, "password", "p", "", "Registry password")
+	listCmd.Flags().BoolVar(&listPre, "pre", false, "Allow version ranges to match pre-release tags")
 }
 
 func runList(cmd *cobra.Command, args []string) {
-	ref := args[0]
-
-	verbose, _ := cmd.Flags().GetBool("verbose")
 	insecure, _ := cmd.Flags().GetBool("insecure")
 
 	ctx := context.Background()
 
-	repo, err := createRepository(ref, insecure)
+	listing, err := bolter.List(ctx, args[0], bolter.ListOptions{
+		Username:   listUsername,
+		Password:   listPassword,
+		Insecure:   insecure,
+		Prerelease: listPre,
+		Logger:     newLogger(cmd),
+	})
 	if err != nil {
-		exitWithError("failed to create repository", err)
+		exitWithError("failed to list reference", err)
 	}
-
-	// Try to get credentials
-	username := listUsername
-	password := listPassword
-
-	// If credentials not provided via flags, try Docker config
-	if username == "" || password == "" {
-		if dockerUser, dockerPass, found := getDockerCredentials(repo.Reference.Registry); found {
-			username = dockerUser
-			password = dockerPass
-			if verbose {
-				fmt.Printf("Using credentials from ~/.docker/config.json\n")
-			}
-		}
+	if listing.Range != "" {
+		fmt.Printf("Resolved %s to %s (%s)\n", listing.Range, listing.Tag, listing.Digest)
 	}
 
-	if username != "" && password != "" {
-		repo.Client = &auth.Client{
-			Client:     retry.DefaultClient,
-			Cache:      auth.NewCache(),
-			Credential: auth.StaticCredential(repo.Reference.Registry, auth.Credential{
-				Username: username,
-				Password: password,
-			}),
-		}
-	}
-
-	descriptor, err := repo.Resolve(ctx, repo.Reference.Reference)
-	if err != nil {
-		exitWithError("failed to resolve reference", err)
-	}
-
-	if verbose {
-		fmt.Printf("Resolved: %s\n", descriptor.Digest)
-		fmt.Printf("Media Type: %s\n", descriptor.MediaType)
-	}
-
-	if descriptor.MediaType == ocispec.MediaTypeImageIndex {
-		if err := listIndex(ctx, repo, descriptor); err != nil {
-			exitWithError("failed to list index", err)
-		}
-	} else if descriptor.MediaType == ocispec.MediaTypeImageManifest {
-		if err := listManifest(ctx, repo, descriptor); err != nil {
-			exitWithError("failed to list manifest", err)
-		}
+	if listing.Manifest != nil {
+		printManifest(listing.Manifest)
 	} else {
-		fmt.Printf("Unknown media type: %s\n", descriptor.MediaType)
+		printIndex(listing.Manifests)
 	}
 }
 
-func listIndex(ctx context.Context, repo *remote.Repository, descriptor ocispec.Descriptor) error {
-	rc, err := repo.Fetch(ctx, descriptor)
-	if err != nil {
-		return err
-	}
-	defer rc.Close()
-
-	indexBytes, err := io.ReadAll(rc)
-	if err != nil {
-		return err
-	}
-
-	var index ocispec.Index
-	if err := json.Unmarshal(indexBytes, &index); err != nil {
-		return err
-	}
-
-	fmt.Printf("Available platforms (%d):\n", len(index.Manifests))
-	for _, manifest := range index.Manifests {
+func printIndex(manifests []ocispec.Descriptor) {
+	fmt.Printf("Available platforms (%d):\n", len(manifests))
+	for _, manifest := range manifests {
 		if manifest.Platform != nil {
-			fmt.Printf("  %s/%s (digest: %s, size: %d bytes)\n",
-				manifest.Platform.OS,
-				manifest.Platform.Architecture,
+			fmt.Printf("  %s (digest: %s, size: %d bytes)\n",
+				formatPlatform(manifest.Platform),
 				manifest.Digest,
 				manifest.Size,
 			)
+			printAnnotationLines("    ", manifest.Annotations)
 		}
 	}
-
-	return nil
 }
 
-func listManifest(ctx context.Context, repo *remote.Repository, descriptor ocispec.Descriptor) error {
-	rc, err := repo.Fetch(ctx, descriptor)
-	if err != nil {
-		return err
-	}
-	defer rc.Close()
-
-	manifestBytes, err := io.ReadAll(rc)
-	if err != nil {
-		return err
-	}
-
-	var manifest ocispec.Manifest
-	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
-		return err
-	}
-
+func printManifest(manifest *ocispec.Manifest) {
 	fmt.Printf("Single platform manifest:\n")
-	if descriptor.Platform != nil {
-		fmt.Printf("  Platform: %s/%s\n", descriptor.Platform.OS, descriptor.Platform.Architecture)
+	if len(manifest.Annotations) > 0 {
+		fmt.Printf("  Annotations:\n")
+		printAnnotationLines("    ", manifest.Annotations)
 	}
 	fmt.Printf("  Layers: %d\n", len(manifest.Layers))
 	for i, layer := range manifest.Layers {
 		fmt.Printf("    [%d] %s (size: %d bytes)\n", i, layer.Digest, layer.Size)
 	}
+}
 
-	return nil
+// formatPlatform formats a platform as "os/arch[/variant]", followed by the
+// OS version if there is one
+func formatPlatform(p *ocispec.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	if p.OSVersion != "" {
+		s += " (os.version " + p.OSVersion + ")"
+	}
+	return s
 }
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aep/bolter/pkg/bolter"
+	"github.com/spf13/cobra"
+)
+
+var loadCmd = &cobra.Command{
+	Use:   "load [file|dir][:tag] [repository[:tag]]",
+	Short: "Push an artifact from an OCI image layout to a registry",
+	Long: `Push an artifact saved with bolter save, from a tarball or a layout
+directory, to a registry. Digests stay the same.
+
+If the layout contains several tags, select one with :tag after the path.
+If the repository has no tag, the tag from the layout is used.
+
+Example:
+  bolter load tool.tar registry.internal/org/tool
+  bolter load ./layout:v1.0 registry.internal/org/tool:v1.0`,
+	Args: cobra.ExactArgs(2),
+	Run:  runLoad,
+}
+
+var (
+	loadUsername string
+	loadPassword string
+)
+
+func init() {
+	rootCmd.AddCommand(loadCmd)
+	loadCmd.Flags().StringVarP(&loadUsername, "username", "u", "", "Registry username")
+	loadCmd.Flags().StringVarP(&loadPassword, "password", "p", "", "Registry password")
+}
+
+func runLoad(cmd *cobra.Command, args []string) {
+	src, ref := args[0], args[1]
+
+	insecure, _ := cmd.Flags().GetBool("insecure")
+
+	ctx := context.Background()
+
+	opts := bolter.LoadOptions{
+		Username: loadUsername,
+		Password: loadPassword,
+		Insecure: insecure,
+		Logger:   newLogger(cmd),
+	}
+
+	desc, err := bolter.Load(ctx, src, ref, opts)
+	if err != nil {
+		exitWithError("load failed", err)
+	}
+
+	fmt.Printf("Successfully loaded %s to %s (%s)\n", src, ref, desc.Digest)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/aep/bolter/pkg/bolter"
+	"github.com/spf13/cobra"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Resolve the tools in bolter.yaml and write bolter.lock",
+	Long: `Resolve every tool declared in bolter.yaml to a digest for every platform
+it is available for, and write the result to bolter.lock next to it.
+
+Example bolter.yaml:
+  tools:
+    golangci-lint: ghcr.io/org/golangci-lint@^1.55
+    protoc:
+      ref: ghcr.io/org/protoc
+      version: ~25.1`,
+	Args: cobra.NoArgs,
+	Run:  runLock,
+}
+
+var (
+	lockUsername string
+	lockPassword string
+)
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+	lockCmd.Flags().StringVarP(&lockUsername, "username", "u", "", "Registry username")
+	lockCmd.Flags().StringVarP(&lockPassword, "password", "p", "", "Registry password")
+}
+
+func runLock(cmd *cobra.Command, args []string) {
+	insecure, _ := cmd.Flags().GetBool("insecure")
+
+	project := loadProject()
+
+	ctx := context.Background()
+
+	lockfile, err := bolter.Lock(ctx, project, bolter.LockOptions{
+		Username: lockUsername,
+		Password: lockPassword,
+		Insecure: insecure,
+		Logger:   newLogger(cmd),
+	})
+	if err != nil {
+		exitWithError("lock failed", err)
+	}
+
+	if err := lockfile.Save(project.LockPath()); err != nil {
+		exitWithError("failed to write lockfile", err)
+	}
+
+	names := make([]string, 0, len(lockfile.Tools))
+	for name := range lockfile.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		tool := lockfile.Tools[name]
+		fmt.Printf("Locked %s to %s (%s, %d platforms)\n", name, tool.Resolved, tool.Digest, len(tool.Platforms))
+	}
+}
+
+// loadProject loads the bolter.yaml of the current directory or its parents
+func loadProject() *bolter.Project {
+	wd, err := os.Getwd()
+	if err != nil {
+		exitWithError("failed to get working directory", err)
+	}
+
+	path, err := bolter.FindProject(wd)
+	if err != nil {
+		exitWithError("failed to find project", err)
+	}
+
+	project, err := bolter.LoadProject(path)
+	if err != nil {
+		exitWithError("failed to load project", err)
+	}
+
+	return project
+}
+
+// loadLockfile loads the bolter.lock belonging to project
+func loadLockfile(project *bolter.Project) *bolter.Lockfile {
+	lockfile, err := bolter.LoadLockfile(project.LockPath())
+	if os.IsNotExist(err) {
+		exitWithError(fmt.Sprintf("%s not found, run 'bolter lock' first", bolter.LockFile), nil)
+	}
+	if err != nil {
+		exitWithError("failed to load lockfile", err)
+	}
+
+	return lockfile
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aep/bolter/pkg/bolter"
+	"github.com/aep/bolter/pkg/credentials"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login [registry]",
+	Short: "Log in to a registry",
+	Long: `Check credentials against a registry and store them for later use.
+
+Credentials are stored through the credential helper configured for the
+registry in the Docker configuration, or otherwise in a bolter credentials
+file that is only readable by the current user.
+
+Example:
+  echo "$TOKEN" | bolter login ghcr.io -u me --password-stdin`,
+	Args: cobra.ExactArgs(1),
+	Run:  runLogin,
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout [registry]",
+	Short: "Log out from a registry",
+	Long:  `Remove the credentials stored for a registry by bolter login.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   runLogout,
+}
+
+var (
+	loginUsername      string
+	loginPassword      string
+	loginPasswordStdin bool
+)
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+	loginCmd.Flags().StringVarP(&loginUsername, "username", "u", "", "Registry username")
+	loginCmd.Flags().StringVarP(&loginPassword, "password", "p", "", "Registry password (prefer --password-stdin)")
+	loginCmd.Flags().BoolVar(&loginPasswordStdin, "password-stdin", false, "Read the password from stdin")
+}
+
+func runLogin(cmd *cobra.Command, args []string) {
+	registry := args[0]
+
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	insecure, _ := cmd.Flags().GetBool("insecure")
+
+	username := loginUsername
+	password := loginPassword
+
+	if loginPasswordStdin {
+		if password != "" {
+			exitWithError("--password and --password-stdin are mutually exclusive", nil)
+		}
+		if username == "" {
+			exitWithError("--username is required with --password-stdin", nil)
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			exitWithError("failed to read password from stdin", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	} else if password != "" {
+		fmt.Fprintln(os.Stderr, "Warning: using --password on the command line is insecure, use --password-stdin")
+	}
+
+	if username == "" || password == "" {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			exitWithError("username and password required, use -u and --password-stdin", nil)
+		}
+		var err error
+		if username, password, err = promptCredentials(username); err != nil {
+			exitWithError("failed to read credentials", err)
+		}
+	}
+
+	ctx := context.Background()
+
+	opts := bolter.LoginOptions{
+		Username: username,
+		Password: password,
+		Insecure: insecure,
+		Logger:   newLogger(cmd),
+	}
+
+	location, err := bolter.Login(ctx, registry, opts)
+	if err != nil {
+		exitWithError("login failed", err)
+	}
+
+	if verbose {
+		fmt.Printf("Credentials stored in %s\n", location)
+	}
+	fmt.Println("Login succeeded")
+}
+
+func runLogout(cmd *cobra.Command, args []string) {
+	registry := args[0]
+
+	if err := bolter.Logout(registry); err != nil {
+		if errors.Is(err, credentials.ErrNotLoggedIn) {
+			exitWithError(fmt.Sprintf("not logged in to %s", registry), nil)
+		}
+		exitWithError("logout failed", err)
+	}
+
+	fmt.Printf("Removed credentials for %s\n", registry)
+}
+
+func promptCredentials(username string) (string, string, error) {
+	if username == "" {
+		fmt.Fprint(os.Stderr, "Username: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return "", "", err
+		}
+		username = strings.TrimSpace(line)
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, string(password), nil
+}
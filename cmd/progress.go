@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aep/bolter/pkg/bolter"
+	"golang.org/x/term"
+)
+
+const (
+	progressBarWidth = 30
+	// progressLogInterval is how often progress is logged when stdout is
+	// not a terminal, unless another tenth of the binary was transferred
+	progressLogInterval = 5 * time.Second
+)
+
+// progressRenderer shows bolter progress events, as one redrawn bar per
+// platform on a terminal, or as plain log lines otherwise
+type progressRenderer struct {
+	out       io.Writer
+	tty       bool
+	verb      string
+	order     []string
+	transfers map[string]*transfer
+	drawn     int
+}
+
+type transfer struct {
+	event       bolter.Event
+	started     time.Time
+	finished    time.Time
+	loggedAt    time.Time
+	loggedTenth int64
+	// resumedAt is the number of bytes an earlier attempt transferred
+	resumedAt int64
+}
+
+// newProgressRenderer returns a renderer writing to out. verb describes
+// the transfer in log lines (e.g., "Uploading").
+func newProgressRenderer(out *os.File, verb string) *progressRenderer {
+	return &progressRenderer{
+		out:       out,
+		tty:       term.IsTerminal(int(out.Fd())),
+		verb:      verb,
+		transfers: make(map[string]*transfer),
+	}
+}
+
+// Handle records e and updates the output. It can be used as the Progress
+// callback of bolter options.
+func (r *progressRenderer) Handle(e bolter.Event) {
+	t, ok := r.transfers[e.Platform]
+	if !ok {
+		t = &transfer{started: time.Now(), loggedAt: time.Now()}
+		r.transfers[e.Platform] = t
+		r.order = append(r.order, e.Platform)
+	}
+	if e.Type == bolter.EventStart {
+		t.started = time.Now()
+		t.resumedAt = e.Current
+		if e.Total > 0 {
+			t.loggedTenth = e.Current * 10 / e.Total
+		}
+	}
+	if e.Type == bolter.EventDone || e.Type == bolter.EventSkip || e.Type == bolter.EventError {
+		t.finished = time.Now()
+	}
+	t.event = e
+
+	if r.tty {
+		r.redraw()
+	} else {
+		r.log(e.Platform, t)
+	}
+}
+
+// redraw moves the cursor back over the bars drawn before and draws them again
+func (r *progressRenderer) redraw() {
+	var b strings.Builder
+	if r.drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", r.drawn)
+	}
+
+	width := 0
+	for _, platform := range r.order {
+		width = max(width, len(platform))
+	}
+	for _, platform := range r.order {
+		fmt.Fprintf(&b, "\r\x1b[2K%-*s  %s\n", width, platform, r.transfers[platform].bar())
+	}
+	r.drawn = len(r.order)
+
+	io.WriteString(r.out, b.String())
+}
+
+func (t *transfer) bar() string {
+	e := t.event
+	switch e.Type {
+	case bolter.EventSkip:
+		return fmt.Sprintf("%s already exists", formatSize(e.Total))
+	case bolter.EventError:
+		return fmt.Sprintf("failed: %v", e.Err)
+	}
+
+	filled := progressBarWidth
+	if e.Total > 0 {
+		// Downloads read a byte past the end to detect oversized blobs
+		filled = min(max(int(e.Current*progressBarWidth/e.Total), 0), progressBarWidth)
+	}
+	bar := "[" + strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled) + "]"
+
+	if e.Type == bolter.EventDone {
+		elapsed := t.finished.Sub(t.started)
+		return fmt.Sprintf("%s %s done in %s", bar, formatSize(e.Total), formatDuration(elapsed))
+	}
+
+	rate := t.rate()
+	eta := "--"
+	if rate > 0 {
+		eta = formatDuration(time.Duration(float64(max(e.Total-e.Current, 0)) / rate * float64(time.Second)))
+	}
+	return fmt.Sprintf("%s %s / %s  %s/s  ETA %s", bar, formatSize(e.Current), formatSize(e.Total), formatSize(int64(rate)), eta)
+}
+
+// rate returns the average transfer rate in bytes per second
+func (t *transfer) rate() float64 {
+	elapsed := time.Since(t.started)
+	if !t.finished.IsZero() {
+		elapsed = t.finished.Sub(t.started)
+	}
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(t.event.Current-t.resumedAt) / elapsed.Seconds()
+}
+
+// log prints a line for the start and end of each transfer, and for
+// progress every tenth of the binary or progressLogInterval
+func (r *progressRenderer) log(platform string, t *transfer) {
+	e := t.event
+	switch e.Type {
+	case bolter.EventStart:
+		if e.Current > 0 {
+			fmt.Fprintf(r.out, "%s %s (%s), resuming at %s\n", r.verb, platform, formatSize(e.Total), formatSize(e.Current))
+		} else {
+			fmt.Fprintf(r.out, "%s %s (%s)\n", r.verb, platform, formatSize(e.Total))
+		}
+	case bolter.EventProgress:
+		var tenth int64
+		if e.Total > 0 {
+			tenth = e.Current * 10 / e.Total
+		}
+		if e.Current == e.Total || (tenth <= t.loggedTenth && time.Since(t.loggedAt) < progressLogInterval) {
+			return
+		}
+		t.loggedTenth, t.loggedAt = tenth, time.Now()
+		fmt.Fprintf(r.out, "%s: %s / %s (%d%%), %s/s\n", platform, formatSize(e.Current), formatSize(e.Total), percent(e.Current, e.Total), formatSize(int64(t.rate())))
+	case bolter.EventDone:
+		fmt.Fprintf(r.out, "%s: done, %s in %s\n", platform, formatSize(e.Total), formatDuration(t.finished.Sub(t.started)))
+	case bolter.EventSkip:
+		fmt.Fprintf(r.out, "%s: %s already exists, skipped\n", platform, formatSize(e.Total))
+	case bolter.EventError:
+		fmt.Fprintf(r.out, "%s: failed: %v\n", platform, e.Err)
+	}
+}
+
+func percent(current, total int64) int64 {
+	if total <= 0 {
+		return 100
+	}
+	return current * 100 / total
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
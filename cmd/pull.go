@@ -3,16 +3,20 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/aep/bolter/pkg/bolter"
 	"github.com/spf13/cobra"
 )
 
 var pullCmd = &cobra.Command{
-	Use:   "pull [repository:tag] [output]",
+	Use:   "pull [repository:tag|repository@range] [output]",
 	Short: "Pull a binary for the current or specified architecture",
 	Long: `Pull a binary artifact for the current architecture or a specified platform.
-The binary will be saved to the specified output path.`,
+The binary will be saved to the specified output path.
+A version range such as "@^1.4" pulls the highest matching tag.
+Binaries saved with bolter save are pulled from oci-archive:<file>:<tag>
+or oci-layout:<dir>:<tag>.`,
 	Args: cobra.RangeArgs(1, 2),
 	Run:  runPull,
 }
@@ -21,13 +25,15 @@ var (
 	pullUsername string
 	pullPassword string
 	pullPlatform string
+	pullPre      bool
 )
 
 func init() {
 	rootCmd.AddCommand(pullCmd)
 	pullCmd.Flags().StringVarP(&pullUsername, "username", "u", "", "Registry username")
 	pullCmd.Flags().StringVarP(&pullPassword, "password", "p", "", "Registry password")
-	pullCmd.Flags().StringVar(&pullPlatform, "platform", "", "Platform to pull (e.g., linux/amd64). Defaults to current platform")
+	pullCmd.Flags().StringVar(&pullPlatform, "platform", "", "Platform to pull (e.g., linux/amd64 or linux/arm/v7). Defaults to current platform")
+	pullCmd.Flags().BoolVar(&pullPre, "pre", false, "Allow version ranges to match pre-release tags")
 }
 
 func runPull(cmd *cobra.Command, args []string) {
@@ -37,19 +43,20 @@ func runPull(cmd *cobra.Command, args []string) {
 		output = args[1]
 	}
 
-	verbose, _ := cmd.Flags().GetBool("verbose")
 	insecure, _ := cmd.Flags().GetBool("insecure")
 
 	ctx := context.Background()
 
 	opts := bolter.PullOptions{
-		Output:   output,
-		Platform: pullPlatform,
-		Username: pullUsername,
-		Password: pullPassword,
-		Insecure: insecure,
-		Verbose:  verbose,
-		UseCache: true,
+		Output:     output,
+		Platform:   pullPlatform,
+		Username:   pullUsername,
+		Password:   pullPassword,
+		Insecure:   insecure,
+		Logger:     newLogger(cmd),
+		UseCache:   true,
+		Prerelease: pullPre,
+		Progress:   newProgressRenderer(os.Stdout, "Downloading").Handle,
 	}
 
 	info, err := bolter.Pull(ctx, ref, opts)
@@ -57,6 +64,5 @@ func runPull(cmd *cobra.Command, args []string) {
 		exitWithError("pull failed", err)
 	}
 
-	fmt.Printf("Successfully pulled to %s\n", info.Path)
+	fmt.Printf("Successfully pulled %s (%s) to %s\n", info.Reference, info.Digest, info.Path)
 }
-
@@ -6,58 +6,87 @@ import (
 	"os"
 	"strings"
 
-	"github.com/opencontainers/go-digest"
-	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/aep/bolter/pkg/bolter"
 	"github.com/spf13/cobra"
-	"oras.land/oras-go/v2"
-	"oras.land/oras-go/v2/content/memory"
-	"oras.land/oras-go/v2/registry/remote"
-	"oras.land/oras-go/v2/registry/remote/auth"
-	"oras.land/oras-go/v2/registry/remote/retry"
 )
 
 var pushCmd = &cobra.Command{
-	Use:   "push [repository:tag]",
+	Use:   "push [repository[:tag]]",
 	Short: "Push multi-architecture binaries as OCI artifacts",
 	Long: `Push native binaries as OCI artifacts with multi-architecture manifest support.
 
+The platform of each binary is detected from its ELF, Mach-O, PE or WASM
+headers, so a path alone is enough. A declared platform that disagrees with
+the headers is refused unless --force is given.
+
+Binaries are uploaded in parallel, --concurrency at a time, with a progress
+bar per platform when stdout is a terminal and progress lines otherwise.
+
+With --merge, the platforms are added to the index already at the tag,
+so that builds for different platforms can be pushed from separate machines.
+
+The index is tagged as the tag of the repository and every --tag. With
+--immutable, or immutable: true for the repository in the bolter config
+file, existing tags are never moved to a different digest. --dry-run shows
+what would be tagged without uploading anything.
+
+Manifests are annotated with the standard OCI annotations: the version
+from the first tag that is a semantic version, and the creation time,
+revision, version and source from the build info of Go binaries or else
+from the git checkout in the current directory (unless --no-git). Any
+annotation can be set with --annotation, standard ones by their short name:
+  --annotation description="A tool" --annotation licenses=MIT
+
 Example:
+  bolter push ghcr.io/org/tool -t v1.2.3 -t v1.2 -t latest -b ./bin/tool
   bolter push myregistry.io/app:v1.0.0 \
-    -b linux/amd64=./bin/app-linux-amd64 \
-    -b linux/arm64=./bin/app-linux-arm64 \
-    -b darwin/amd64=./bin/app-darwin-amd64`,
+    -b ./bin/app-linux-amd64 \
+    -b ./bin/app-darwin-arm64 \
+    -b linux/arm/v7=./bin/app-linux-armv7 \
+    -b windows/amd64=./bin/app.exe`,
 	Args: cobra.ExactArgs(1),
 	Run:  runPush,
 }
 
 var (
-	pushUsername  string
-	pushPassword  string
-	pushPlatforms []string
+	pushUsername    string
+	pushPassword    string
+	pushPlatforms   []string
+	pushForce       bool
+	pushMerge       bool
+	pushChunkSize   string
+	pushConcurrency int
+	pushTags        []string
+	pushImmutable   bool
+	pushDryRun      bool
+	pushAnnotations []string
+	pushNoGit       bool
 )
 
 func init() {
 	rootCmd.AddCommand(pushCmd)
 	pushCmd.Flags().StringVarP(&pushUsername, "username", "u", "", "Registry username")
 	pushCmd.Flags().StringVarP(&pushPassword, "password", "p", "", "Registry password")
-	pushCmd.Flags().StringArrayVarP(&pushPlatforms, "bin", "b", nil, "Platform mapping in format os/arch=path (e.g., linux/amd64=./bin/myapp)")
+	pushCmd.Flags().StringArrayVarP(&pushPlatforms, "bin", "b", nil, "Binary to push, optionally with its platform as os/arch[/variant]=path (e.g., ./bin/myapp or linux/arm/v7=./bin/myapp)")
+	pushCmd.Flags().BoolVar(&pushForce, "force", false, "Push even if the declared platform does not match the binary headers")
+	pushCmd.Flags().BoolVar(&pushMerge, "merge", false, "Add or replace these platforms in the existing index instead of overwriting the tag")
+	pushCmd.Flags().StringVar(&pushChunkSize, "chunk-size", "16MiB", "Upload binaries in chunks of this size (0 for a single request)")
+	pushCmd.Flags().IntVarP(&pushConcurrency, "concurrency", "j", bolter.DefaultConcurrency, "Number of binaries to upload in parallel")
+	pushCmd.Flags().StringArrayVarP(&pushTags, "tag", "t", nil, "Tag to apply, in addition to the tag of the repository, can be repeated")
+	pushCmd.Flags().BoolVar(&pushImmutable, "immutable", false, "Refuse to move existing tags to a different digest")
+	pushCmd.Flags().BoolVar(&pushDryRun, "dry-run", false, "Show what would be tagged without uploading anything")
+	pushCmd.Flags().StringArrayVarP(&pushAnnotations, "annotation", "a", nil, "Annotation to set as key=value, can be repeated (e.g., description=\"A tool\" or licenses=MIT)")
+	pushCmd.Flags().BoolVar(&pushNoGit, "no-git", false, "Do not fill annotations from the git checkout in the current directory")
 	pushCmd.MarkFlagRequired("bin")
 }
 
-type platformBinary struct {
-	path string
-	os   string
-	arch string
-}
-
 func runPush(cmd *cobra.Command, args []string) {
 	ref := args[0]
 
-	verbose, _ := cmd.Flags().GetBool("verbose")
 	insecure, _ := cmd.Flags().GetBool("insecure")
 
 	if len(pushPlatforms) == 0 {
-		exitWithError("no bin specified, use --bin os/arch=path", nil)
+		exitWithError("no bin specified, use --bin path or --bin os/arch=path", nil)
 	}
 
 	binaries, err := parsePlatformMappings(pushPlatforms)
@@ -65,244 +94,150 @@ func runPush(cmd *cobra.Command, args []string) {
 		exitWithError("failed to parse bin mappings", err)
 	}
 
-	if verbose {
-		fmt.Printf("Pushing %d binaries...\n", len(binaries))
+	chunkSize, err := parseSize(pushChunkSize)
+	if err != nil {
+		exitWithError("invalid --chunk-size", err)
+	}
+	if chunkSize == 0 {
+		chunkSize = -1
 	}
 
-	ctx := context.Background()
-
-	repo, err := createRepository(ref, insecure)
+	annotations, err := parseAnnotations(pushAnnotations)
 	if err != nil {
-		exitWithError("failed to create repository", err)
+		exitWithError("invalid --annotation", err)
 	}
 
-	// Try to get credentials
-	username := pushUsername
-	password := pushPassword
-
-	// If credentials not provided via flags, try Docker config
-	if username == "" || password == "" {
-		if dockerUser, dockerPass, found := getDockerCredentials(repo.Reference.Registry); found {
-			username = dockerUser
-			password = dockerPass
-			if verbose {
-				fmt.Printf("Using credentials from ~/.docker/config.json\n")
-			}
-		}
+	var defaultAnnotations map[string]string
+	if !pushNoGit {
+		defaultAnnotations = gitAnnotations()
 	}
 
-	if username != "" && password != "" {
-		repo.Client = &auth.Client{
-			Client:     retry.DefaultClient,
-			Cache:      auth.NewCache(),
-			Credential: auth.StaticCredential(repo.Reference.Registry, auth.Credential{
-				Username: username,
-				Password: password,
-			}),
-		}
+	immutable := pushImmutable
+	if !immutable {
+		immutable = loadConfig().Repository(ref).Immutable
 	}
 
-	memoryStore := memory.New()
-
-	var manifestDescriptors []ocispec.Descriptor
-
-	for i, binary := range binaries {
-		fmt.Printf("[%d/%d] Pushing %s/%s... ", i+1, len(binaries), binary.os, binary.arch)
-		descriptor, err := pushBinary(ctx, memoryStore, repo, binary, verbose)
-		if err != nil {
-			fmt.Printf("FAILED\n")
-			exitWithError(fmt.Sprintf("failed to push binary %s/%s", binary.os, binary.arch), err)
-		}
-		manifestDescriptors = append(manifestDescriptors, descriptor)
-		fmt.Printf("✓\n")
-	}
+	ctx := context.Background()
 
-	fmt.Printf("Creating manifest index... ")
-	indexDescriptor, err := createAndPushIndex(ctx, memoryStore, repo, manifestDescriptors, verbose)
+	opts := bolter.PushOptions{
+		Username:    pushUsername,
+		Password:    pushPassword,
+		Insecure:    insecure,
+		Logger:      newLogger(cmd),
+		Force:       pushForce,
+		Merge:       pushMerge,
+		ChunkSize:   chunkSize,
+		Concurrency: pushConcurrency,
+		Progress:    newProgressRenderer(os.Stdout, "Uploading").Handle,
+		Tags:        pushTags,
+		Immutable:   immutable,
+		DryRun:      pushDryRun,
+		OnTag:       printTagUpdate,
+
+		Annotations:        annotations,
+		DefaultAnnotations: defaultAnnotations,
+	}
+
+	indexDescriptor, err := bolter.Push(ctx, ref, binaries, opts)
 	if err != nil {
-		fmt.Printf("FAILED\n")
-		exitWithError("failed to create manifest index", err)
+		exitWithError("push failed", err)
 	}
-	fmt.Printf("✓\n")
 
-	if err := repo.Tag(ctx, indexDescriptor, repo.Reference.Reference); err != nil {
-		exitWithError("failed to tag manifest", err)
+	if pushDryRun {
+		fmt.Printf("Dry run, nothing was pushed to %s\n", ref)
+		return
 	}
 
-	fmt.Printf("\nSuccessfully pushed %d binaries to %s\n", len(binaries), ref)
+	fmt.Printf("Successfully pushed %d binaries to %s\n", len(binaries), ref)
 	fmt.Printf("Manifest digest: %s\n", indexDescriptor.Digest)
 }
 
-func parsePlatformMappings(platforms []string) ([]platformBinary, error) {
-	var binaries []platformBinary
-
-	for _, platform := range platforms {
-		parts := strings.SplitN(platform, "=", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid platform format: %s (expected os/arch=path)", platform)
-		}
-
-		osParts := strings.Split(parts[0], "/")
-		if len(osParts) != 2 {
-			return nil, fmt.Errorf("invalid platform format: %s (expected os/arch)", parts[0])
-		}
-
-		path := parts[1]
-		if _, err := os.Stat(path); err != nil {
-			return nil, fmt.Errorf("file not found: %s", path)
-		}
-
-		binaries = append(binaries, platformBinary{
-			path: path,
-			os:   osParts[0],
-			arch: osParts[1],
-		})
-	}
-
-	return binaries, nil
-}
-
-func pushBinary(ctx context.Context, memoryStore *memory.Store, repo *remote.Repository, binary platformBinary, verbose bool) (ocispec.Descriptor, error) {
-	data, err := os.ReadFile(binary.path)
-	if err != nil {
-		return ocispec.Descriptor{}, err
+// printTagUpdate prints a tag set by bolter push, or that would be set with
+// --dry-run
+func printTagUpdate(update bolter.TagUpdate) {
+	verb := "Tagged"
+	if pushDryRun {
+		verb = "Would tag"
 	}
 
-	binaryDesc := ocispec.Descriptor{
-		MediaType: getMediaTypeForPlatform(binary.os, binary.arch),
-		Digest:    digest.FromBytes(data),
-		Size:      int64(len(data)),
-		Annotations: map[string]string{
-			"org.opencontainers.image.title": fmt.Sprintf("%s-%s", binary.os, binary.arch),
-		},
+	switch update.Old {
+	case "":
+		fmt.Printf("%s %s: %s (new)\n", verb, update.Tag, update.New)
+	case update.New:
+		fmt.Printf("%s %s: %s (unchanged)\n", verb, update.Tag, update.New)
+	default:
+		fmt.Printf("%s %s: %s -> %s\n", verb, update.Tag, update.Old, update.New)
 	}
+}
 
-	if err := memoryStore.Push(ctx, binaryDesc, strings.NewReader(string(data))); err != nil {
-		if !strings.Contains(strings.ToLower(err.Error()), "already exists") {
-			return ocispec.Descriptor{}, err
-		}
-	}
+// parsePlatformMappings parses --bin values of the form "path",
+// "os/arch=path" or "os/arch/variant=path". Platforms left out are
+// detected by bolter.Push.
+func parsePlatformMappings(platforms []string) ([]bolter.PlatformBinary, error) {
+	var binaries []bolter.PlatformBinary
 
-	configDesc := ocispec.Descriptor{
-		MediaType: ocispec.MediaTypeImageConfig,
-		Digest:    digest.FromBytes([]byte("{}")),
-		Size:      2,
-	}
+	for _, platform := range platforms {
+		var binary bolter.PlatformBinary
 
-	if err := memoryStore.Push(ctx, configDesc, strings.NewReader("{}")); err != nil {
-		// Ignore "already exists" - same config is used for all binaries
-		if !strings.Contains(strings.ToLower(err.Error()), "already exists") {
-			return ocispec.Descriptor{}, err
+		if spec, path, ok := strings.Cut(platform, "="); ok && isPlatformSpec(spec) {
+			parts := strings.Split(spec, "/")
+			binary.OS, binary.Architecture = parts[0], parts[1]
+			if len(parts) > 2 {
+				binary.Variant = parts[2]
+			}
+			binary.Path = path
+		} else {
+			binary.Path = platform
 		}
-	}
-
-	manifestBytes := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","config":{"mediaType":"%s","digest":"%s","size":%d},"layers":[{"mediaType":"%s","digest":"%s","size":%d,"annotations":{%s}}]}`,
-		ocispec.MediaTypeImageManifest,
-		configDesc.MediaType, configDesc.Digest, configDesc.Size,
-		binaryDesc.MediaType, binaryDesc.Digest, binaryDesc.Size,
-		fmt.Sprintf(`"org.opencontainers.image.title":"%s"`, fmt.Sprintf("%s-%s", binary.os, binary.arch)),
-	))
-
-	manifestDesc := ocispec.Descriptor{
-		MediaType: ocispec.MediaTypeImageManifest,
-		Digest:    digest.FromBytes(manifestBytes),
-		Size:      int64(len(manifestBytes)),
-		Platform: &ocispec.Platform{
-			OS:           binary.os,
-			Architecture: binary.arch,
-		},
-	}
 
-	if err := memoryStore.Push(ctx, manifestDesc, strings.NewReader(string(manifestBytes))); err != nil {
-		if !strings.Contains(strings.ToLower(err.Error()), "already exists") {
-			return ocispec.Descriptor{}, err
+		if _, err := os.Stat(binary.Path); err != nil {
+			return nil, fmt.Errorf("file not found: %s", binary.Path)
 		}
-	}
 
-	if err := oras.CopyGraph(ctx, memoryStore, repo, manifestDesc, oras.DefaultCopyGraphOptions); err != nil {
-		// Ignore "already exists" errors - this just means the blob is already in the registry
-		if !strings.Contains(strings.ToLower(err.Error()), "already exists") {
-			return ocispec.Descriptor{}, err
-		}
+		binaries = append(binaries, binary)
 	}
 
-	return manifestDesc, nil
+	return binaries, nil
 }
 
-func createAndPushIndex(ctx context.Context, memoryStore *memory.Store, repo *remote.Repository, manifests []ocispec.Descriptor, verbose bool) (ocispec.Descriptor, error) {
-	var manifestsJSON strings.Builder
-	manifestsJSON.WriteString("[")
-	for i, m := range manifests {
-		if i > 0 {
-			manifestsJSON.WriteString(",")
-		}
-		manifestsJSON.WriteString(fmt.Sprintf(`{"mediaType":"%s","digest":"%s","size":%d,"platform":{"os":"%s","architecture":"%s"}}`,
-			m.MediaType, m.Digest, m.Size, m.Platform.OS, m.Platform.Architecture))
+// isPlatformSpec reports whether s looks like "os/arch" or
+// "os/arch/variant" rather than a path
+func isPlatformSpec(s string) bool {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return false
 	}
-	manifestsJSON.WriteString("]")
-
-	indexBytes := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","manifests":%s}`,
-		ocispec.MediaTypeImageIndex, manifestsJSON.String()))
-
-	indexDesc := ocispec.Descriptor{
-		MediaType: ocispec.MediaTypeImageIndex,
-		Digest:    digest.FromBytes(indexBytes),
-		Size:      int64(len(indexBytes)),
-	}
-
-	if err := memoryStore.Push(ctx, indexDesc, strings.NewReader(string(indexBytes))); err != nil {
-		if !strings.Contains(strings.ToLower(err.Error()), "already exists") {
-			return ocispec.Descriptor{}, err
+	for _, part := range parts {
+		if !isPlatformPart(part) {
+			return false
 		}
 	}
+	return true
+}
 
-	if err := oras.CopyGraph(ctx, memoryStore, repo, indexDesc, oras.DefaultCopyGraphOptions); err != nil {
-		// Ignore "already exists" errors - this just means the blob is already in the registry
-		if !strings.Contains(strings.ToLower(err.Error()), "already exists") {
-			return ocispec.Descriptor{}, err
+func isPlatformPart(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
 		}
 	}
-
-	return indexDesc, nil
+	return true
 }
 
-func createRepository(ref string, insecure bool) (*remote.Repository, error) {
-	// If ref doesn't contain a registry (no . or : in first component),
-	// default to Docker Hub
-	if !strings.Contains(strings.Split(ref, "/")[0], ".") &&
-	   !strings.Contains(strings.Split(ref, "/")[0], ":") {
-		ref = "docker.io/" + ref
-	}
-
-	repo, err := remote.NewRepository(ref)
+// loadConfig loads the bolter config file, if there is one
+func loadConfig() *bolter.Config {
+	file, err := bolter.ConfigPath()
 	if err != nil {
-		return nil, err
+		return &bolter.Config{}
 	}
 
-	repo.PlainHTTP = insecure
-
-	return repo, nil
-}
-
-func getMediaTypeForPlatform(goos, goarch string) string {
-	// WASM has special handling
-	if goos == "js" || goos == "wasip1" || goarch == "wasm" {
-		return "application/vnd.bolter.wasm.v1"
+	config, err := bolter.LoadConfig(file)
+	if err != nil {
+		exitWithError("failed to load config", err)
 	}
 
-	// Platform-specific binary formats
-	switch goos {
-	case "windows":
-		return "application/vnd.bolter.windows.exe.v1"
-	case "darwin":
-		return "application/vnd.bolter.macho.v1"
-	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly", "solaris", "illumos":
-		return "application/vnd.bolter.elf.v1"
-	case "plan9":
-		return "application/vnd.bolter.plan9.v1"
-	case "aix":
-		return "application/vnd.bolter.xcoff.v1"
-	default:
-		return "application/vnd.bolter.binary.v1"
-	}
+	return config
 }
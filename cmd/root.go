@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 
+	"github.com/aep/bolter/pkg/bolter"
 	"github.com/spf13/cobra"
 )
 
@@ -24,11 +27,67 @@ func init() {
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose output")
 }
 
+// newLogger returns the logger passed to the library. It writes to stderr,
+// so that it does not mix with the output of commands or of binaries run.
+// Warnings are always shown, everything else only with --verbose.
+func newLogger(cmd *cobra.Command) *slog.Logger {
+	level := slog.LevelWarn
+	if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+		level = slog.LevelDebug
+	}
+
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Timestamps are noise on an interactive terminal
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// Exit codes of the CLI. bolter run exits with the code of the binary it ran
+// instead, once the binary was started.
+const (
+	exitFailure          = 1
+	exitNotFound         = 2
+	exitUnauthorized     = 3
+	exitPlatformNotFound = 4
+	exitDigestMismatch   = 5
+	exitImmutableTag     = 6
+)
+
 func exitWithError(msg string, err error) {
+	// The binary has already reported its own failure
+	var exitErr *bolter.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", msg, err)
 	} else {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
 	}
-	os.Exit(1)
+	os.Exit(exitCode(err))
+}
+
+// exitCode returns the exit code for err
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, bolter.ErrPlatformNotFound):
+		return exitPlatformNotFound
+	case errors.Is(err, bolter.ErrUnauthorized):
+		return exitUnauthorized
+	case errors.Is(err, bolter.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, bolter.ErrDigestMismatch):
+		return exitDigestMismatch
+	case errors.Is(err, bolter.ErrImmutableTag):
+		return exitImmutableTag
+	default:
+		return exitFailure
+	}
 }
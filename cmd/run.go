@@ -2,16 +2,31 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"time"
 
 	"github.com/aep/bolter/pkg/bolter"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var executeCmd = &cobra.Command{
-	Use:   "run [repository:tag] [-- args...]",
+	Use:   "run [repository:tag|repository@range|tool] [-- args...]",
 	Short: "Execute a binary from the registry",
 	Long: `Download (if not cached) and execute a binary from the registry for the current platform.
-Binaries are cached locally to avoid repeated downloads.`,
+Binaries are cached locally to avoid repeated downloads.
+
+A version range can be given instead of a tag, in which case the highest
+matching semantic version tag is used. Tools declared in bolter.yaml can be
+run by name, using the digest pinned in bolter.lock. Binaries saved with
+bolter save are run from oci-archive:<file>:<tag> or oci-layout:<dir>:<tag>.
+
+Example:
+  bolter run ghcr.io/org/tool@^1.4 -- --help
+  bolter run ghcr.io/org/tool@latest-stable
+  bolter run golangci-lint -- run ./...
+  bolter run oci-archive:tool.tar:v1.0 -- --help`,
 	Args: cobra.MinimumNArgs(1),
 	Run:  runExecute,
 }
@@ -21,33 +36,54 @@ var (
 	executePassword string
 	executePlatform string
 	executeNoCache  bool
+	executeTTL      time.Duration
+	executePre      bool
 )
 
 func init() {
 	rootCmd.AddCommand(executeCmd)
 	executeCmd.Flags().StringVarP(&executeUsername, "username", "u", "", "Registry username")
 	executeCmd.Flags().StringVarP(&executePassword, "password", "p", "", "Registry password")
-	executeCmd.Flags().StringVar(&executePlatform, "platform", "", "Platform to execute (e.g., linux/amd64). Defaults to current platform")
+	executeCmd.Flags().StringVar(&executePlatform, "platform", "", "Platform to execute (e.g., linux/amd64 or linux/arm/v7). Defaults to current platform")
 	executeCmd.Flags().BoolVar(&executeNoCache, "no-cache", false, "Don't use cached binaries, always download")
+	executeCmd.Flags().DurationVar(&executeTTL, "resolve-ttl", bolter.DefaultResolveTTL, "How long a cached tag is used before checking the registry for updates (0 to always check)")
+	executeCmd.Flags().BoolVar(&executePre, "pre", false, "Allow version ranges to match pre-release tags")
 }
 
 func runExecute(cmd *cobra.Command, args []string) {
 	ref := args[0]
 	execArgs := args[1:]
 
-	verbose, _ := cmd.Flags().GetBool("verbose")
 	insecure, _ := cmd.Flags().GetBool("insecure")
 
+	// A zero TTL on the command line means "always check"
+	resolveTTL := executeTTL
+	if resolveTTL == 0 {
+		resolveTTL = -1
+	}
+
+	if bolter.IsToolName(ref) {
+		ref = resolveToolReference(ref)
+	}
+
 	ctx := context.Background()
 
 	opts := bolter.RunOptions{
-		Platform: executePlatform,
-		Username: executeUsername,
-		Password: executePassword,
-		Insecure: insecure,
-		Verbose:  verbose,
-		NoCache:  executeNoCache,
-		UseExec:  true, // CLI uses syscall.Exec to replace process
+		Platform:   executePlatform,
+		Username:   executeUsername,
+		Password:   executePassword,
+		Insecure:   insecure,
+		Logger:     newLogger(cmd),
+		NoCache:    executeNoCache,
+		ResolveTTL: resolveTTL,
+		Prerelease: executePre,
+		UseExec:    true, // CLI uses syscall.Exec to replace process
+	}
+
+	// The output of the binary is not interleaved with progress lines, so
+	// progress is only shown as bars on an interactive terminal
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		opts.Progress = newProgressRenderer(os.Stderr, "Downloading").Handle
 	}
 
 	if err := bolter.Run(ctx, ref, execArgs, opts); err != nil {
@@ -55,3 +91,31 @@ func runExecute(cmd *cobra.Command, args []string) {
 	}
 }
 
+// resolveToolReference returns the locked reference of a tool declared in the
+// project's bolter.yaml, or name unchanged if there is no such tool
+func resolveToolReference(name string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return name
+	}
+
+	path, err := bolter.FindProject(wd)
+	if err != nil {
+		return name
+	}
+
+	project, err := bolter.LoadProject(path)
+	if err != nil {
+		exitWithError("failed to load project", err)
+	}
+	if _, ok := project.Tools[name]; !ok {
+		return name
+	}
+
+	ref, err := loadLockfile(project).Reference(name)
+	if err != nil {
+		exitWithError(fmt.Sprintf("%s is not locked, run 'bolter lock'", name), err)
+	}
+
+	return ref
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aep/bolter/pkg/bolter"
+	"github.com/spf13/cobra"
+)
+
+var saveCmd = &cobra.Command{
+	Use:   "save [repository:tag|repository@range]",
+	Short: "Save an artifact to an OCI image layout for air-gapped transfer",
+	Long: `Save the index, every platform manifest and every binary of an artifact
+into an OCI image layout, either as a tarball (-o) or as a directory (--layout).
+
+The saved artifact can be pushed to another registry with bolter load, or
+pulled and run directly as oci-archive:<file>:<tag> or oci-layout:<dir>:<tag>.
+
+Example:
+  bolter save ghcr.io/org/tool:v1.0 -o tool.tar
+  bolter save ghcr.io/org/tool:v1.0 --layout ./layout --platform linux/amd64
+  bolter run oci-archive:tool.tar:v1.0 -- --help`,
+	Args: cobra.ExactArgs(1),
+	Run:  runSave,
+}
+
+var (
+	saveUsername  string
+	savePassword  string
+	saveOutput    string
+	saveLayout    string
+	savePlatforms []string
+	savePre       bool
+)
+
+func init() {
+	rootCmd.AddCommand(saveCmd)
+	saveCmd.Flags().StringVarP(&saveUsername, "username", "u", "", "Registry username")
+	saveCmd.Flags().StringVarP(&savePassword, "password", "p", "", "Registry password")
+	saveCmd.Flags().StringVarP(&saveOutput, "output", "o", "", "Write the layout as a tarball to this file")
+	saveCmd.Flags().StringVar(&saveLayout, "layout", "", "Write the layout to this directory, adding to an existing layout")
+	saveCmd.Flags().StringArrayVar(&savePlatforms, "platform", nil, "Only save this platform (e.g., linux/amd64), can be repeated")
+	saveCmd.Flags().BoolVar(&savePre, "pre", false, "Allow version ranges to match pre-release tags")
+	saveCmd.MarkFlagsOneRequired("output", "layout")
+	saveCmd.MarkFlagsMutuallyExclusive("output", "layout")
+}
+
+func runSave(cmd *cobra.Command, args []string) {
+	ref := args[0]
+
+	insecure, _ := cmd.Flags().GetBool("insecure")
+
+	ctx := context.Background()
+
+	opts := bolter.SaveOptions{
+		Output:     saveOutput,
+		Layout:     saveLayout,
+		Platforms:  savePlatforms,
+		Username:   saveUsername,
+		Password:   savePassword,
+		Insecure:   insecure,
+		Logger:     newLogger(cmd),
+		Prerelease: savePre,
+	}
+
+	desc, err := bolter.Save(ctx, ref, opts)
+	if err != nil {
+		exitWithError("save failed", err)
+	}
+
+	dest := saveOutput
+	if dest == "" {
+		dest = saveLayout
+	}
+	fmt.Printf("Successfully saved %s (%s) to %s\n", ref, desc.Digest, dest)
+}
@@ -3,17 +3,22 @@ package bolter
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
-	"syscall"
 	"time"
 
+	"github.com/aep/bolter/pkg/credentials"
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
 	"oras.land/oras-go/v2/registry/remote/retry"
@@ -31,12 +36,21 @@ type PullOptions struct {
 	Password string
 	// Insecure allows insecure registry connections
 	Insecure bool
-	// Verbose enables verbose output
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+	// Verbose logs status messages to stderr if Logger is nil.
+	//
+	// Deprecated: Set Logger instead.
 	Verbose bool
 	// UseCache enables caching of downloaded binaries
 	UseCache bool
 	// CacheDir overrides the default cache directory (~/.cache/bolter)
 	CacheDir string
+	// Prerelease allows version ranges to resolve to pre-release tags
+	Prerelease bool
+	// Progress is called with the download progress of the binary.
+	// It is not called when the binary is served from the cache.
+	Progress func(Event)
 }
 
 // RunOptions configures the Run operation
@@ -49,21 +63,67 @@ type RunOptions struct {
 	Password string
 	// Insecure allows insecure registry connections
 	Insecure bool
-	// Verbose enables verbose output
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+	// Verbose logs status messages to stderr if Logger is nil.
+	//
+	// Deprecated: Set Logger instead.
 	Verbose bool
-	// NoCache disables use of cached binaries
+	// NoCache disables use of cached binaries. The binary is downloaded
+	// again and not recorded in the cache.
 	NoCache bool
+	// ResolveTTL is how long a cached tag is used before it is resolved
+	// against the registry again. Zero uses the TTL the tag was cached with
+	// (DefaultResolveTTL), a negative value resolves on every run.
+	ResolveTTL time.Duration
+	// Prerelease allows version ranges to resolve to pre-release tags
+	Prerelease bool
 	// CacheDir overrides the default cache directory (~/.cache/bolter)
 	CacheDir string
+	// Progress is called with the download progress of the binary.
+	// It is not called when the binary is served from the cache.
+	Progress func(Event)
 	// UseExec uses syscall.Exec() to replace the current process (CLI behavior)
 	// If false, uses exec.Command() and returns after execution
 	UseExec bool
+	// GracefulSignal is sent to the binary when ctx is cancelled. Defaults to
+	// SIGTERM (os.Kill on Windows). Only used when UseExec is false.
+	GracefulSignal os.Signal
+	// KillTimeout is how long the binary may take to exit after
+	// GracefulSignal before it is killed. Zero uses DefaultKillTimeout.
+	KillTimeout time.Duration
+	// ForwardSignals are relayed to the binary when this process receives
+	// them while the binary runs. Nil forwards SIGINT, SIGTERM, SIGHUP and
+	// SIGQUIT (nothing on Windows); an empty slice forwards none. SIGINT
+	// and SIGQUIT are not forwarded while the binary is in the foreground
+	// of a terminal, which sends them to the binary itself.
+	ForwardSignals []os.Signal
+	// Env is the environment of the binary. Nil inherits the environment of
+	// this process; use append(os.Environ(), ...) to extend it.
+	Env []string
+	// Dir is the working directory of the binary. Empty uses the current
+	// directory.
+	Dir string
+	// Stdin, Stdout and Stderr connect the binary's standard streams. Run
+	// uses the streams of this process for nil values, Command leaves them
+	// unset. Ignored when UseExec is set.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Argv0 is passed to the binary as argv[0]. Empty uses the path of the
+	// cached binary.
+	Argv0 string
 }
 
 // BinaryInfo contains information about a pulled binary
 type BinaryInfo struct {
 	// Path to the binary file
 	Path string
+	// Reference the binary was pulled from, with version ranges resolved
+	// to a concrete tag (e.g., "ghcr.io/org/tool:v1.4.2")
+	Reference string
+	// Tag the reference resolved to. Empty for digest references.
+	Tag string
 	// Digest of the manifest
 	Digest string
 	// Size of the binary in bytes
@@ -72,16 +132,40 @@ type BinaryInfo struct {
 	OS string
 	// Architecture of the binary
 	Architecture string
+	// Variant of the architecture (e.g., "v7" for arm), if any
+	Variant string
 	// Cached indicates if the binary was served from cache
 	Cached bool
 }
 
+// platform returns the platform of the binary in format "os/arch" or
+// "os/arch/variant"
+func (i *BinaryInfo) platform() string {
+	return platformString(ocispec.Platform{OS: i.OS, Architecture: i.Architecture, Variant: i.Variant})
+}
+
 // Pull downloads a binary from an OCI registry
-func Pull(ctx context.Context, ref string, opts PullOptions) (*BinaryInfo, error) {
-	targetOS, targetArch := parsePlatform(opts.Platform)
+func Pull(ctx context.Context, ref string, opts PullOptions) (_ *BinaryInfo, err error) {
+	defer func() { err = classifyError(err) }()
+	opts.Logger = verboseLogger(opts.Logger, opts.Verbose)
+
+	target := parsePlatform(opts.Platform)
+	log := logger(opts.Logger)
+
+	log.Info("Pulling", "ref", ref, "platform", platformString(target))
+
+	if lr, ok := parseLayoutReference(ref); ok {
+		info, err := pullLayout(ctx, lr, opts, false)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Pulled binary", "ref", info.Reference, "digest", info.Digest, "platform", info.platform(), "path", info.Path, "cached", info.Cached)
+		return info, nil
+	}
 
-	if opts.Verbose {
-		fmt.Printf("Pulling for %s/%s...\n", targetOS, targetArch)
+	base, rng, isRange := splitVersionRange(ref)
+	if isRange {
+		ref = base
 	}
 
 	repo, err := createRepository(ref, opts.Insecure)
@@ -90,362 +174,472 @@ func Pull(ctx context.Context, ref string, opts PullOptions) (*BinaryInfo, error
 	}
 
 	// Setup authentication
-	if err := setupAuth(repo, opts.Username, opts.Password, opts.Verbose); err != nil {
+	if err := setupAuth(repo, opts.Username, opts.Password, log); err != nil {
 		return nil, err
 	}
 
+	if isRange {
+		tag, err := resolveVersionRange(ctx, repo, rng, opts.Prerelease)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve version range: %w", err)
+		}
+		repo.Reference.Reference = tag
+	}
+
+	var cacheDir string
+	if opts.UseCache {
+		cacheDir, err = getCacheDir(opts.CacheDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cache directory: %w", err)
+		}
+	} else if opts.Output == "" {
+		return nil, fmt.Errorf("output path required when caching is disabled")
+	}
+
 	descriptor, err := repo.Resolve(ctx, repo.Reference.Reference)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve reference: %w", err)
 	}
 
-	var manifestDesc *ocispec.Descriptor
+	if isRange {
+		log.Info("Resolved version range", "range", rng, "tag", repo.Reference.Reference, "digest", descriptor.Digest)
+	}
 
-	if descriptor.MediaType == ocispec.MediaTypeImageIndex {
-		manifestDesc, err = findManifestForPlatform(ctx, repo, descriptor, targetOS, targetArch)
-		if err != nil {
-			return nil, fmt.Errorf("failed to find manifest for platform: %w", err)
-		}
-	} else if descriptor.MediaType == ocispec.MediaTypeImageManifest {
-		manifestDesc = &descriptor
-	} else {
-		return nil, fmt.Errorf("unsupported media type: %s", descriptor.MediaType)
+	manifestDesc, err := selectManifest(ctx, repo, cacheDir, descriptor, target)
+	if err != nil {
+		return nil, err
+	}
+
+	layerDesc, err := resolveLayer(ctx, repo, cacheDir, manifestDesc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull binary: %w", err)
 	}
 
-	// Determine output path
 	outputPath := opts.Output
-	var cacheDir string
-	var cachedBinary string
+	cached := false
+	progress := newProgressReporter(opts.Progress, platformString(manifestPlatform(manifestDesc, target)), layerDesc.Digest, layerDesc.Size)
 
 	if opts.UseCache {
-		cacheDir, err = getCacheDir(opts.CacheDir)
+		blobPath, fromCache, err := fetchBlobToCache(ctx, repo, cacheDir, layerDesc, false, progress)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get cache directory: %w", err)
+			return nil, fmt.Errorf("failed to pull binary: %w", err)
+		}
+		cached = fromCache
+
+		if !isDigestReference(repo.Reference) {
+			if err := saveCacheRef(cacheDir, repo.Reference, descriptor, DefaultResolveTTL); err != nil {
+				log.Warn("Failed to save cache ref", "ref", repo.Reference, "error", err)
+			}
+		}
+		if err := saveCacheMetadata(cacheDir, repo.Reference, manifestPlatform(manifestDesc, target), manifestDesc, layerDesc); err != nil {
+			log.Warn("Failed to save cache metadata", "ref", repo.Reference, "error", err)
 		}
-		cachedBinary = getCachePathForRef(cacheDir, repo.Reference.Registry, repo.Reference.Repository, repo.Reference.Reference, targetOS, targetArch)
 
 		// If no output specified, use cache path
 		if outputPath == "" {
-			outputPath = cachedBinary
+			outputPath = blobPath
+		} else {
+			if outputDir := filepath.Dir(outputPath); outputDir != "." && outputDir != "" {
+				if err := os.MkdirAll(outputDir, 0755); err != nil {
+					return nil, err
+				}
+			}
+			if err := copyFile(blobPath, outputPath); err != nil {
+				return nil, fmt.Errorf("failed to copy binary from cache: %w", err)
+			}
 		}
-	} else if outputPath == "" {
-		return nil, fmt.Errorf("output path required when caching is disabled")
-	}
-
-	// Pull the binary
-	if err := pullBinary(ctx, repo, *manifestDesc, outputPath); err != nil {
+	} else if err := downloadBlob(ctx, repo, layerDesc, outputPath, progress); err != nil {
 		return nil, fmt.Errorf("failed to pull binary: %w", err)
 	}
 
-	if err := os.Chmod(outputPath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to make binary executable: %w", err)
-	}
-
-	// Get file info
-	fileInfo, err := os.Stat(outputPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat binary: %w", err)
+	var tag string
+	if !isDigestReference(repo.Reference) {
+		tag = repo.Reference.Reference
 	}
 
+	platform := manifestPlatform(manifestDesc, target)
 	info := &BinaryInfo{
 		Path:         outputPath,
+		Reference:    repo.Reference.String(),
+		Tag:          tag,
 		Digest:       manifestDesc.Digest.String(),
-		Size:         fileInfo.Size(),
-		OS:           targetOS,
-		Architecture: targetArch,
-		Cached:       false,
-	}
-
-	// Save to cache if using cache and output is not the cache path
-	if opts.UseCache && outputPath != cachedBinary {
-		if err := os.MkdirAll(filepath.Dir(cachedBinary), 0755); err == nil {
-			if err := copyFile(outputPath, cachedBinary); err == nil {
-				os.Chmod(cachedBinary, 0755)
-				if err := saveCacheMetadata(cacheDir, repo.Reference.Registry, repo.Reference.Repository, repo.Reference.Reference, targetOS, targetArch, manifestDesc.Digest.String(), fileInfo.Size()); err != nil && opts.Verbose {
-					fmt.Printf("Warning: failed to save cache metadata: %v\n", err)
-				}
-			} else if opts.Verbose {
-				fmt.Printf("Warning: failed to cache binary: %v\n", err)
-			}
-		}
-	} else if opts.UseCache {
-		// Save cache metadata
-		if err := saveCacheMetadata(cacheDir, repo.Reference.Registry, repo.Reference.Repository, repo.Reference.Reference, targetOS, targetArch, manifestDesc.Digest.String(), fileInfo.Size()); err != nil && opts.Verbose {
-			fmt.Printf("Warning: failed to save cache metadata: %v\n", err)
-		}
+		Size:         layerDesc.Size,
+		OS:           platform.OS,
+		Architecture: platform.Architecture,
+		Variant:      platform.Variant,
+		Cached:       cached,
 	}
 
-	if opts.Verbose {
-		fmt.Printf("Successfully pulled to %s\n", outputPath)
-	}
+	log.Info("Pulled binary", "ref", info.Reference, "digest", layerDesc.Digest, "platform", platformString(platform), "path", outputPath, "cached", cached)
 
 	return info, nil
 }
 
-// Run downloads (if not cached) and executes a binary from an OCI registry
-func Run(ctx context.Context, ref string, args []string, opts RunOptions) error {
-	targetOS, targetArch := parsePlatform(opts.Platform)
+// Run downloads (if not cached) and executes a binary from an OCI registry.
+// Unless opts.UseExec is set, the binary runs as a child process that is
+// stopped when ctx is cancelled, and a non-zero exit is returned as an
+// *ExitError.
+func Run(ctx context.Context, ref string, args []string, opts RunOptions) (err error) {
+	defer func() { err = classifyError(err) }()
+	opts.Logger = verboseLogger(opts.Logger, opts.Verbose)
 
-	cacheDir, err := getCacheDir(opts.CacheDir)
+	binaryPath, err := fetchRunBinary(ctx, ref, opts, "Running binary")
 	if err != nil {
-		return fmt.Errorf("failed to get cache directory: %w", err)
+		return err
 	}
 
-	repo, err := createRepository(ref, opts.Insecure)
+	return executeBinary(ctx, binaryPath, args, opts)
+}
+
+// Command downloads (if not cached) a binary from an OCI registry and
+// returns an unstarted command that runs it with args. The command is
+// configured from opts like Run configures the child process, including
+// stopping it when ctx is cancelled. UseExec and ForwardSignals are ignored,
+// and nil streams are left unset so that methods such as Output can be used.
+func Command(ctx context.Context, ref string, args []string, opts RunOptions) (_ *exec.Cmd, err error) {
+	defer func() { err = classifyError(err) }()
+	opts.Logger = verboseLogger(opts.Logger, opts.Verbose)
+
+	binaryPath, err := fetchRunBinary(ctx, ref, opts, "Prepared binary")
 	if err != nil {
-		return fmt.Errorf("failed to create repository: %w", err)
+		return nil, err
 	}
 
-	cachedBinary := getCachePathForRef(cacheDir, repo.Reference.Registry, repo.Reference.Repository, repo.Reference.Reference, targetOS, targetArch)
+	return newCommand(ctx, binaryPath, args, opts)
+}
 
-	// Check cache first
-	if !opts.NoCache {
-		if _, err := os.Stat(cachedBinary); err == nil {
-			if opts.Verbose {
-				fmt.Printf("Using cached binary: %s\n", cachedBinary)
-			}
-			return executeBinary(cachedBinary, args, opts.UseExec)
-		}
+// fetchRunBinary resolves ref like Run does and returns the path of the
+// cached binary, logging msg once it is available
+func fetchRunBinary(ctx context.Context, ref string, opts RunOptions, msg string) (string, error) {
+	if lr, ok := parseLayoutReference(ref); ok {
+		return fetchLayoutBinary(ctx, lr, opts, msg)
 	}
 
-	if opts.Verbose {
-		fmt.Printf("Pulling binary for %s/%s...\n", targetOS, targetArch)
+	target := parsePlatform(opts.Platform)
+	log := logger(opts.Logger)
+
+	cacheDir, err := getCacheDir(opts.CacheDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %w", err)
 	}
 
-	// Setup authentication
-	if err := setupAuth(repo, opts.Username, opts.Password, opts.Verbose); err != nil {
-		return err
+	base, rng, isRange := splitVersionRange(ref)
+	if isRange {
+		ref = base
 	}
 
-	descriptor, err := repo.Resolve(ctx, repo.Reference.Reference)
+	repo, err := createRepository(ref, opts.Insecure)
 	if err != nil {
-		return fmt.Errorf("failed to resolve reference: %w", err)
+		return "", fmt.Errorf("failed to create repository: %w", err)
 	}
 
-	var manifestDesc *ocispec.Descriptor
+	// Setup authentication
+	if err := setupAuth(repo, opts.Username, opts.Password, log); err != nil {
+		return "", err
+	}
 
-	if descriptor.MediaType == ocispec.MediaTypeImageIndex {
-		manifestDesc, err = findManifestForPlatform(ctx, repo, descriptor, targetOS, targetArch)
+	if isRange {
+		tag, err := resolveCachedVersionRange(ctx, repo, cacheDir, rng, opts)
 		if err != nil {
-			return fmt.Errorf("failed to find manifest for platform: %w", err)
+			return "", fmt.Errorf("failed to resolve version range: %w", err)
 		}
-	} else if descriptor.MediaType == ocispec.MediaTypeImageManifest {
-		manifestDesc = &descriptor
-	} else {
-		return fmt.Errorf("unsupported media type: %s", descriptor.MediaType)
+		repo.Reference.Reference = tag
 	}
 
-	if err := os.MkdirAll(filepath.Dir(cachedBinary), 0755); err != nil {
-		return fmt.Errorf("failed to create cache directory: %w", err)
+	descriptor, err := resolveCachedReference(ctx, repo, cacheDir, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve reference: %w", err)
 	}
 
-	if err := pullBinary(ctx, repo, *manifestDesc, cachedBinary); err != nil {
-		return fmt.Errorf("failed to pull binary: %w", err)
+	if isRange {
+		log.Info("Resolved version range", "range", rng, "tag", repo.Reference.Reference, "digest", descriptor.Digest)
 	}
 
-	if err := os.Chmod(cachedBinary, 0755); err != nil {
-		return fmt.Errorf("failed to make binary executable: %w", err)
+	manifestDesc, err := selectManifest(ctx, repo, cacheDir, descriptor, target)
+	if err != nil {
+		return "", err
 	}
 
-	// Save cache metadata
-	fileInfo, err := os.Stat(cachedBinary)
-	if err == nil {
-		if err := saveCacheMetadata(cacheDir, repo.Reference.Registry, repo.Reference.Repository, repo.Reference.Reference, targetOS, targetArch, manifestDesc.Digest.String(), fileInfo.Size()); err != nil && opts.Verbose {
-			fmt.Printf("Warning: failed to save cache metadata: %v\n", err)
-		}
+	layerDesc, err := resolveLayer(ctx, repo, cacheDir, manifestDesc)
+	if err != nil {
+		return "", fmt.Errorf("failed to pull binary: %w", err)
 	}
 
-	if opts.Verbose {
-		fmt.Printf("Pulled to cache: %s\n", cachedBinary)
+	progress := newProgressReporter(opts.Progress, platformString(manifestPlatform(manifestDesc, target)), layerDesc.Digest, layerDesc.Size)
+	binaryPath, cached, err := fetchBlobToCache(ctx, repo, cacheDir, layerDesc, opts.NoCache, progress)
+	if err != nil {
+		return "", fmt.Errorf("failed to pull binary: %w", err)
 	}
 
-	return executeBinary(cachedBinary, args, opts.UseExec)
-}
+	// Record the use of the entry for LRU eviction. Binaries run without
+	// the cache are not recorded, so garbage collection removes them.
+	if !opts.NoCache {
+		if err := saveCacheMetadata(cacheDir, repo.Reference, manifestPlatform(manifestDesc, target), manifestDesc, layerDesc); err != nil {
+			log.Warn("Failed to save cache metadata", "ref", repo.Reference, "error", err)
+		}
+	}
 
-// Helper functions
+	log.Info(msg, "ref", repo.Reference, "digest", layerDesc.Digest, "platform", platformString(manifestPlatform(manifestDesc, target)), "path", binaryPath, "cached", cached)
 
-func parsePlatform(platform string) (goos, goarch string) {
-	if platform == "" {
-		return runtime.GOOS, runtime.GOARCH
-	}
+	return binaryPath, nil
+}
 
-	for i, ch := range platform {
-		if ch == '/' {
-			return platform[:i], platform[i+1:]
+// resolveCachedReference resolves the reference of repo to a descriptor.
+// Digest references only need to be resolved if their content is not
+// cached. Tags are served from their cached ref file until it expires;
+// after that the tag is re-resolved with a HEAD request, falling back to the
+// cached digest if the registry is unreachable.
+func resolveCachedReference(ctx context.Context, repo *remote.Repository, cacheDir string, opts RunOptions) (ocispec.Descriptor, error) {
+	if isDigestReference(repo.Reference) {
+		dgst, _ := repo.Reference.Digest()
+		if !opts.NoCache && verifyFile(getBlobPath(cacheDir, dgst), dgst) == nil {
+			return ocispec.Descriptor{Digest: dgst}, nil
 		}
+		// The registry needs the media type and size to fetch the manifest
+		return repo.Resolve(ctx, repo.Reference.Reference)
 	}
-	return runtime.GOOS, runtime.GOARCH
-}
 
-func createRepository(ref string, insecure bool) (*remote.Repository, error) {
-	// If ref doesn't contain a registry (no . or : in first component),
-	// default to Docker Hub
-	if !strings.Contains(strings.Split(ref, "/")[0], ".") &&
-		!strings.Contains(strings.Split(ref, "/")[0], ":") {
-		ref = "docker.io/" + ref
+	var cached *cacheRef
+	if !opts.NoCache {
+		cached, _ = loadCacheRef(cacheDir, repo.Reference)
+		if cached != nil {
+			// An explicit TTL overrides the one the ref was saved with
+			expiresAt := cached.ExpiresAt
+			if opts.ResolveTTL != 0 {
+				expiresAt = cached.ResolvedAt.Add(opts.ResolveTTL)
+			}
+			if time.Now().Before(expiresAt) {
+				return cached.descriptor(), nil
+			}
+		}
 	}
 
-	repo, err := remote.NewRepository(ref)
-	if err != nil {
-		return nil, err
+	ttl := opts.ResolveTTL
+	if ttl <= 0 {
+		ttl = DefaultResolveTTL
 	}
 
-	repo.PlainHTTP = insecure
-
-	return repo, nil
-}
-
-func setupAuth(repo *remote.Repository, username, password string, verbose bool) error {
-	// Try Docker config if credentials not provided
-	if username == "" || password == "" {
-		if dockerUser, dockerPass, found := getDockerCredentials(repo.Reference.Registry); found {
-			username = dockerUser
-			password = dockerPass
-			if verbose {
-				fmt.Printf("Using credentials from ~/.docker/config.json\n")
-			}
+	descriptor, err := repo.Resolve(ctx, repo.Reference.Reference)
+	if err != nil {
+		// Only fall back to the cached digest when the registry could not
+		// be asked, not when it says the tag is gone
+		if cached != nil && !errors.Is(err, errdef.ErrNotFound) {
+			logger(opts.Logger).Warn("Failed to resolve tag, using cached digest", "ref", repo.Reference, "digest", cached.Digest, "error", err)
+			return cached.descriptor(), nil
 		}
+		return ocispec.Descriptor{}, err
 	}
 
-	if username != "" && password != "" {
-		repo.Client = &auth.Client{
-			Client: retry.DefaultClient,
-			Cache:  auth.NewCache(),
-			Credential: auth.StaticCredential(repo.Reference.Registry, auth.Credential{
-				Username: username,
-				Password: password,
-			}),
+	if !opts.NoCache {
+		if err := saveCacheRef(cacheDir, repo.Reference, descriptor, ttl); err != nil {
+			logger(opts.Logger).Warn("Failed to save cache ref", "ref", repo.Reference, "error", err)
 		}
 	}
 
-	return nil
+	return descriptor, nil
 }
 
-func findManifestForPlatform(ctx context.Context, repo *remote.Repository, indexDesc ocispec.Descriptor, targetOS, targetArch string) (*ocispec.Descriptor, error) {
-	rc, err := repo.Fetch(ctx, indexDesc)
-	if err != nil {
-		return nil, err
-	}
-	defer rc.Close()
-
-	indexBytes, err := io.ReadAll(rc)
+// selectManifest returns the manifest for the target platform, looking into
+// the index if desc refers to one.
+func selectManifest(ctx context.Context, src content.Fetcher, cacheDir string, desc ocispec.Descriptor, target ocispec.Platform) (ocispec.Descriptor, error) {
+	data, err := fetchContent(ctx, src, cacheDir, desc)
 	if err != nil {
-		return nil, err
+		return ocispec.Descriptor{}, fmt.Errorf("failed to fetch %s: %w", desc.Digest, err)
 	}
 
-	var index ocispec.Index
-	if err := json.Unmarshal(indexBytes, &index); err != nil {
-		return nil, err
+	// Descriptors of cached digest references carry no media type
+	mediaType := desc.MediaType
+	if mediaType == "" {
+		var probe struct {
+			MediaType string `json:"mediaType"`
+		}
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		mediaType = probe.MediaType
 	}
 
-	for _, manifest := range index.Manifests {
-		if manifest.Platform != nil &&
-			manifest.Platform.OS == targetOS &&
-			manifest.Platform.Architecture == targetArch {
-			return &manifest, nil
+	switch mediaType {
+	case ocispec.MediaTypeImageIndex:
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return ocispec.Descriptor{}, err
 		}
+		manifestDesc, err := findManifestForPlatform(index, target)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to find manifest for platform: %w", err)
+		}
+		return *manifestDesc, nil
+	case ocispec.MediaTypeImageManifest:
+		desc.MediaType = mediaType
+		return desc, nil
+	default:
+		return ocispec.Descriptor{}, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
 	}
-
-	return nil, fmt.Errorf("no manifest found for %s/%s", targetOS, targetArch)
 }
 
-func pullBinary(ctx context.Context, repo *remote.Repository, manifestDesc ocispec.Descriptor, output string) error {
-	rc, err := repo.Fetch(ctx, manifestDesc)
-	if err != nil {
-		return err
-	}
-	defer rc.Close()
-
-	manifestBytes, err := io.ReadAll(rc)
+// resolveLayer returns the descriptor of the binary layer of a manifest
+func resolveLayer(ctx context.Context, src content.Fetcher, cacheDir string, manifestDesc ocispec.Descriptor) (ocispec.Descriptor, error) {
+	manifestBytes, err := fetchContent(ctx, src, cacheDir, manifestDesc)
 	if err != nil {
-		return err
+		return ocispec.Descriptor{}, err
 	}
 
 	var manifest ocispec.Manifest
 	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
-		return err
+		return ocispec.Descriptor{}, err
 	}
 
 	if len(manifest.Layers) == 0 {
-		return fmt.Errorf("manifest has no layers")
+		return ocispec.Descriptor{}, fmt.Errorf("manifest has no layers")
 	}
 
-	layerDesc := manifest.Layers[0]
+	return manifest.Layers[0], nil
+}
+
+// Helper functions
 
-	rc, err = repo.Fetch(ctx, layerDesc)
+func createRepository(ref string, insecure bool) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(normalizeReference(ref))
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	repo.PlainHTTP = insecure
+
+	return repo, nil
+}
+
+// normalizeReference defaults references without a registry to Docker Hub
+func normalizeReference(ref string) string {
+	// If ref doesn't contain a registry (no . or : in first component),
+	// default to Docker Hub
+	if !strings.Contains(strings.Split(ref, "/")[0], ".") &&
+		!strings.Contains(strings.Split(ref, "/")[0], ":") {
+		ref = "docker.io/" + ref
+	}
+	return ref
+}
+
+func parseReference(ref string) (registry.Reference, error) {
+	return registry.ParseReference(normalizeReference(ref))
+}
+
+func setupAuth(repo *remote.Repository, username, password string, log *slog.Logger) error {
+	cred := credentials.Credential{
+		Username: username,
+		Password: password,
 	}
-	defer rc.Close()
 
-	outputDir := filepath.Dir(output)
-	if outputDir != "." && outputDir != "" {
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			return err
+	// Try stored credentials and Docker config if credentials not provided
+	if cred.IsEmpty() {
+		storedCred, err := credentials.Lookup(repo.Reference.Registry)
+		if err != nil {
+			log.Warn("Failed to read stored credentials", "registry", repo.Reference.Registry, "error", err)
+		} else if !storedCred.IsEmpty() {
+			cred = storedCred
+			log.Debug("Using stored credentials", "registry", repo.Reference.Registry)
 		}
 	}
 
-	outFile, err := os.Create(output)
-	if err != nil {
-		return err
+	if !cred.IsEmpty() {
+		repo.Client = &auth.Client{
+			Client:     retry.DefaultClient,
+			Cache:      auth.NewCache(),
+			Credential: auth.StaticCredential(repo.Reference.Registry, authCredential(cred)),
+		}
 	}
-	defer outFile.Close()
 
-	_, err = io.Copy(outFile, rc)
-	return err
+	return nil
 }
 
-func executeBinary(binaryPath string, args []string, useExec bool) error {
-	binary, err := exec.LookPath(binaryPath)
-	if err != nil {
-		return err
+// verboseLogger returns l, or a Debug level logger writing to stderr for
+// the deprecated Verbose options if l is nil
+func verboseLogger(l *slog.Logger, verbose bool) *slog.Logger {
+	if l == nil && verbose {
+		return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	return l
+}
+
+// logger returns l, or a logger that discards everything if l is nil
+func logger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.New(slog.DiscardHandler)
 	}
+	return l
+}
 
-	if useExec {
-		// Replace current process (CLI behavior)
-		execArgs := append([]string{binary}, args...)
-		env := os.Environ()
-		return syscall.Exec(binary, execArgs, env)
+// authCredential converts a stored credential into an oras credential,
+// passing identity tokens as refresh tokens
+func authCredential(cred credentials.Credential) auth.Credential {
+	return auth.Credential{
+		Username:     cred.Username,
+		Password:     cred.Password,
+		RefreshToken: cred.IdentityToken,
 	}
+}
 
-	// Run as subprocess (library behavior)
-	cmd := exec.Command(binary, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Env = os.Environ()
+// copyVerified copies r to w while hashing it, and fails with a
+// DigestMismatchError if the content does not match desc.
+func copyVerified(w io.Writer, r io.Reader, desc ocispec.Descriptor) error {
+	if err := desc.Digest.Validate(); err != nil {
+		return fmt.Errorf("invalid layer digest: %w", err)
+	}
 
-	if err := cmd.Run(); err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return fmt.Errorf("binary exited with code %d", exitErr.ExitCode())
-		}
+	hasher := desc.Digest.Algorithm().Hash()
+
+	// Read one byte past the expected size so oversized content is detected
+	n, err := io.Copy(io.MultiWriter(w, hasher), io.LimitReader(r, desc.Size+1))
+	if err != nil {
 		return err
 	}
 
+	actual := digest.NewDigest(desc.Digest.Algorithm(), hasher)
+	if n != desc.Size || actual != desc.Digest {
+		return &DigestMismatchError{
+			Expected:     desc.Digest,
+			Actual:       actual,
+			ExpectedSize: desc.Size,
+			ActualSize:   n,
+		}
+	}
+
 	return nil
 }
 
-func getCacheDir(customCacheDir string) (string, error) {
-	if customCacheDir != "" {
-		return customCacheDir, nil
+// verifyFile checks that the file at path has the given digest.
+func verifyFile(path string, expected digest.Digest) error {
+	if err := expected.Validate(); err != nil {
+		return fmt.Errorf("invalid digest: %w", err)
 	}
 
-	homeDir, err := os.UserHomeDir()
+	f, err := os.Open(path)
 	if err != nil {
-		return "", err
+		return err
 	}
+	defer f.Close()
 
-	return filepath.Join(homeDir, ".cache", "bolter"), nil
-}
+	hasher := expected.Algorithm().Hash()
+	n, err := io.Copy(hasher, f)
+	if err != nil {
+		return err
+	}
 
-func getCachePathForRef(cacheDir, registry, repository, tag, goos, arch string) string {
-	// Normalize registry name
-	registry = strings.ReplaceAll(registry, ":", "_")
-	repository = strings.ReplaceAll(repository, "/", "_")
+	actual := digest.NewDigest(expected.Algorithm(), hasher)
+	if actual != expected {
+		return &DigestMismatchError{
+			Expected:     expected,
+			Actual:       actual,
+			ExpectedSize: -1,
+			ActualSize:   n,
+		}
+	}
 
-	return filepath.Join(cacheDir, registry, repository, tag, fmt.Sprintf("%s-%s", goos, arch))
+	return nil
 }
 
+// copyFile copies an executable from src to dst, replacing dst atomically.
 func copyFile(src, dst string) error {
 	sourceFile, err := os.Open(src)
 	if err != nil {
@@ -453,52 +647,27 @@ func copyFile(src, dst string) error {
 	}
 	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
+	destFile, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	return err
-}
-
-type cacheMetadata struct {
-	Registry     string    `json:"registry"`
-	Repository   string    `json:"repository"`
-	Tag          string    `json:"tag"`
-	OS           string    `json:"os"`
-	Architecture string    `json:"architecture"`
-	Digest       string    `json:"digest"`
-	Size         int64     `json:"size"`
-	CachedAt     time.Time `json:"cached_at"`
-}
+	tmpPath := destFile.Name()
 
-func saveCacheMetadata(cacheDir, registry, repository, tag, goos, arch, digest string, size int64) error {
-	registry = strings.ReplaceAll(registry, ":", "_")
-	repository = strings.ReplaceAll(repository, "/", "_")
-
-	metaPath := filepath.Join(cacheDir, registry, repository, tag, "metadata.json")
-
-	meta := cacheMetadata{
-		Registry:     registry,
-		Repository:   repository,
-		Tag:          tag,
-		OS:           goos,
-		Architecture: arch,
-		Digest:       digest,
-		Size:         size,
-		CachedAt:     time.Now(),
+	if _, err := io.Copy(destFile, sourceFile); err != nil {
+		destFile.Close()
+		os.Remove(tmpPath)
+		return err
 	}
 
-	data, err := json.MarshalIndent(meta, "", "  ")
-	if err != nil {
+	if err := destFile.Close(); err != nil {
+		os.Remove(tmpPath)
 		return err
 	}
 
-	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+	if err := os.Chmod(tmpPath, 0755); err != nil {
+		os.Remove(tmpPath)
 		return err
 	}
 
-	return os.WriteFile(metaPath, data, 0644)
+	return os.Rename(tmpPath, dst)
 }
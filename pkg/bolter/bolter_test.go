@@ -0,0 +1,123 @@
+package bolter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestCopyVerified(t *testing.T) {
+	content := "#!/bin/sh\necho hello\n"
+	desc := ocispec.Descriptor{
+		Digest: digest.FromString(content),
+		Size:   int64(len(content)),
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"match", content, false},
+		{"truncated", content[:10], true},
+		{"oversized", content + "extra", true},
+		{"corrupted", strings.Replace(content, "hello", "HELLO", 1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := copyVerified(&buf, strings.NewReader(tt.input), desc)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if buf.String() != content {
+					t.Fatalf("copied content differs")
+				}
+				return
+			}
+
+			var mismatch *DigestMismatchError
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("expected DigestMismatchError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLockCacheEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entry")
+
+	unlock, err := lockCacheEntry(context.Background(), path)
+	if err != nil {
+		t.Fatalf("failed to take lock: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := lockCacheEntry(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected second lock to wait until deadline, got %v", err)
+	}
+
+	unlock()
+
+	unlock, err = lockCacheEntry(context.Background(), path)
+	if err != nil {
+		t.Fatalf("failed to take lock after release: %v", err)
+	}
+	unlock()
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRunByDigestColdCache(t *testing.T) {
+	_, host := newTestRegistry(t)
+	ref := host + "/org/tool:v1"
+
+	pushTestBinary(t, ref, "pinned build")
+	resolved, err := Resolve(context.Background(), ref, ResolveOptions{Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing of the digest is cached yet, so it has to be resolved
+	pinned := host + "/org/tool@" + resolved.Digest
+	cacheDir := t.TempDir()
+	if got := runBinaryContent(t, pinned, RunOptions{CacheDir: cacheDir}); got != "pinned build" {
+		t.Fatalf("ran %q, want pinned build", got)
+	}
+	if got := runBinaryContent(t, pinned, RunOptions{CacheDir: cacheDir}); got != "pinned build" {
+		t.Fatalf("ran %q from the cache, want pinned build", got)
+	}
+}
+
+func TestVerboseLogger(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+	if verboseLogger(l, true) != l {
+		t.Error("Logger is not used when Verbose is set")
+	}
+	if verboseLogger(nil, false) != nil {
+		t.Error("logger without Verbose")
+	}
+	if got := verboseLogger(nil, true); got == nil || !got.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Verbose does not log debug messages")
+	}
+}
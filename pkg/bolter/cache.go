@@ -0,0 +1,790 @@
+package bolter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry"
+)
+
+// The cache is content-addressable. Indexes, manifests and binaries are
+// stored once per digest, and tags are small ref files pointing at a digest:
+//
+//	<cacheDir>/blobs/<algorithm>/<encoded>
+//	<cacheDir>/refs/<registry>/<repository>/_refs/<tag>/ref.json
+//	<cacheDir>/refs/<registry>/<repository>/_refs/<tag>/platforms/<os>-<arch>.json
+//
+// The repository is stored as nested directories. No repository path
+// component can be named "_refs", so distinct references never share a
+// directory. ":" in ports and digests is stored as "@", which neither hosts
+// nor tags may contain.
+
+// DefaultResolveTTL is how long Run trusts a cached tag before resolving it
+// against the registry again
+const DefaultResolveTTL = time.Hour
+
+func getCacheDir(customCacheDir string) (string, error) {
+	if customCacheDir != "" {
+		return customCacheDir, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".cache", "bolter"), nil
+}
+
+func getBlobPath(cacheDir string, dgst digest.Digest) string {
+	return filepath.Join(cacheDir, "blobs", dgst.Algorithm().String(), dgst.Encoded())
+}
+
+func getRefDir(cacheDir, registry, repository, reference string) string {
+	registry = strings.ReplaceAll(registry, ":", "@")
+	reference = strings.ReplaceAll(reference, ":", "@")
+
+	return filepath.Join(cacheDir, "refs", registry, filepath.FromSlash(repository), "_refs", reference)
+}
+
+func isDigestReference(ref registry.Reference) bool {
+	return ref.ValidateReferenceAsDigest() == nil
+}
+
+// cacheRef maps a tag to the digest it resolved to, or a version range to
+// the tag it resolved to
+type cacheRef struct {
+	Reference  string    `json:"reference"`
+	Tag        string    `json:"tag,omitempty"`
+	Digest     string    `json:"digest,omitempty"`
+	MediaType  string    `json:"media_type"`
+	Size       int64     `json:"size"`
+	ResolvedAt time.Time `json:"resolved_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func (r *cacheRef) descriptor() ocispec.Descriptor {
+	return ocispec.Descriptor{
+		MediaType: r.MediaType,
+		Digest:    digest.Digest(r.Digest),
+		Size:      r.Size,
+	}
+}
+
+func loadCacheRef(cacheDir string, ref registry.Reference) (*cacheRef, error) {
+	refPath := filepath.Join(getRefDir(cacheDir, ref.Registry, ref.Repository, ref.Reference), "ref.json")
+
+	data, err := os.ReadFile(refPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var cr cacheRef
+	if err := json.Unmarshal(data, &cr); err != nil {
+		return nil, err
+	}
+
+	if cr.Digest == "" && cr.Tag == "" {
+		return nil, fmt.Errorf("%s resolves to nothing", refPath)
+	}
+
+	if err := digest.Digest(cr.Digest).Validate(); cr.Digest != "" && err != nil {
+		return nil, fmt.Errorf("invalid digest in %s: %w", refPath, err)
+	}
+
+	return &cr, nil
+}
+
+func saveCacheRef(cacheDir string, ref registry.Reference, desc ocispec.Descriptor, ttl time.Duration) error {
+	now := time.Now()
+	cr := cacheRef{
+		Reference:  ref.String(),
+		Digest:     desc.Digest.String(),
+		MediaType:  desc.MediaType,
+		Size:       desc.Size,
+		ResolvedAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+
+	return writeCacheRef(cacheDir, ref, cr)
+}
+
+func writeCacheRef(cacheDir string, ref registry.Reference, cr cacheRef) error {
+	refPath := filepath.Join(getRefDir(cacheDir, ref.Registry, ref.Repository, ref.Reference), "ref.json")
+
+	data, err := json.MarshalIndent(cr, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(refPath), 0755); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(refPath, data, 0644)
+}
+
+// fetchContent returns the content of a manifest or index, reading it from
+// the cache when present. Content fetched from the registry is verified and
+// stored in the cache. An empty cacheDir disables the cache.
+func fetchContent(ctx context.Context, src content.Fetcher, cacheDir string, desc ocispec.Descriptor) ([]byte, error) {
+	var blobPath string
+	if cacheDir != "" {
+		blobPath = getBlobPath(cacheDir, desc.Digest)
+		if data, err := os.ReadFile(blobPath); err == nil && desc.Digest.Validate() == nil && desc.Digest.Algorithm().FromBytes(data) == desc.Digest {
+			return data, nil
+		}
+	}
+
+	data, err := content.FetchAll(ctx, src, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	if blobPath != "" {
+		if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err == nil {
+			writeFileAtomic(blobPath, data, 0644)
+		}
+	}
+
+	return data, nil
+}
+
+// fetchBlobToCache makes sure the blob described by desc is in the cache,
+// downloading it unless a verified copy is already present (or refresh is
+// set). Concurrent callers for the same blob wait for a single download.
+// It returns the path of the blob and whether it was served from the cache.
+func fetchBlobToCache(ctx context.Context, src content.Fetcher, cacheDir string, desc ocispec.Descriptor, refresh bool, progress *progressReporter) (string, bool, error) {
+	blobPath := getBlobPath(cacheDir, desc.Digest)
+
+	if !refresh && verifyFile(blobPath, desc.Digest) == nil {
+		// Keep garbage collection away until the caller records the blob
+		now := time.Now()
+		os.Chtimes(blobPath, now, now)
+		return blobPath, true, nil
+	}
+
+	unlock, err := lockCacheEntry(ctx, blobPath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to lock cache entry: %w", err)
+	}
+	defer unlock()
+
+	// Another process may have finished the download while we waited
+	if !refresh && verifyFile(blobPath, desc.Digest) == nil {
+		return blobPath, true, nil
+	}
+
+	if err := downloadBlob(ctx, src, desc, blobPath, progress); err != nil {
+		return "", false, err
+	}
+
+	return blobPath, false, nil
+}
+
+// CacheEntry describes a binary in the local cache for one platform of a
+// cached reference
+type CacheEntry struct {
+	// Reference the binary was pulled as (e.g., "ghcr.io/me/app:v1.0.0")
+	Reference string `json:"reference"`
+	// Registry the binary was pulled from
+	Registry string `json:"registry"`
+	// Repository within the registry
+	Repository string `json:"repository"`
+	// Tag or digest that was requested
+	Tag string `json:"tag"`
+	// OS of the binary
+	OS string `json:"os"`
+	// Architecture of the binary
+	Architecture string `json:"architecture"`
+	// Variant of the architecture (e.g., "v7" for arm), if any
+	Variant string `json:"variant,omitempty"`
+	// Digest of the platform manifest
+	Digest string `json:"digest"`
+	// LayerDigest is the digest of the binary itself
+	LayerDigest string `json:"layer_digest"`
+	// MediaType of the binary layer
+	MediaType string `json:"media_type"`
+	// Size of the binary in bytes
+	Size int64 `json:"size"`
+	// CachedAt is when the binary was added to the cache
+	CachedAt time.Time `json:"cached_at"`
+	// LastUsed is when the binary was last pulled or run from the cache
+	LastUsed time.Time `json:"last_used"`
+	// Path to the cached binary
+	Path string `json:"-"`
+
+	// recordPath is the path of the metadata file of this entry
+	recordPath string
+}
+
+// Platform returns the platform of the entry in format "os/arch" or
+// "os/arch/variant"
+func (e *CacheEntry) Platform() string {
+	return platformString(ocispec.Platform{OS: e.OS, Architecture: e.Architecture, Variant: e.Variant})
+}
+
+func (e *CacheEntry) lastUsed() time.Time {
+	if e.LastUsed.IsZero() {
+		return e.CachedAt
+	}
+	return e.LastUsed
+}
+
+func getCacheEntryPath(cacheDir string, ref registry.Reference, platform ocispec.Platform) string {
+	name := platform.OS + "-" + platform.Architecture
+	if platform.Variant != "" {
+		name += "-" + platform.Variant
+	}
+	return filepath.Join(getRefDir(cacheDir, ref.Registry, ref.Repository, ref.Reference), "platforms", name+".json")
+}
+
+// saveCacheMetadata records that the binary for a platform of ref was just
+// pulled or run from the cache. The time it was first cached is kept as long
+// as the entry still refers to the same binary.
+func saveCacheMetadata(cacheDir string, ref registry.Reference, platform ocispec.Platform, manifestDesc, layerDesc ocispec.Descriptor) error {
+	entry := CacheEntry{
+		Reference:  ref.String(),
+		Registry:   ref.Registry,
+		Repository: ref.Repository,
+		Tag:        ref.Reference,
+	}
+	return writeCacheMetadata(getCacheEntryPath(cacheDir, ref, platform), entry, platform, manifestDesc, layerDesc)
+}
+
+// saveLayoutCacheMetadata records a binary pulled or run from an OCI
+// layout, so that it is listed and kept by garbage collection like binaries
+// from registries. Layouts are stored by the hash of their absolute path
+// below the "_layouts" registry directory, which no registry host can be
+// named.
+func saveLayoutCacheMetadata(cacheDir string, lr layoutReference, platform ocispec.Platform, manifestDesc, layerDesc ocispec.Descriptor) error {
+	abs, err := filepath.Abs(lr.Path)
+	if err != nil {
+		return err
+	}
+	lr.Path = abs
+
+	key := sha256.Sum256([]byte(abs))
+	dirRef := registry.Reference{Registry: "_layouts", Repository: hex.EncodeToString(key[:8]), Reference: lr.Reference}
+
+	entry := CacheEntry{
+		Reference:  lr.String(),
+		Registry:   strings.TrimSuffix(layoutPrefix, ":"),
+		Repository: abs,
+		Tag:        lr.Reference,
+	}
+	if lr.Archive {
+		entry.Registry = strings.TrimSuffix(archivePrefix, ":")
+	}
+	return writeCacheMetadata(getCacheEntryPath(cacheDir, dirRef, platform), entry, platform, manifestDesc, layerDesc)
+}
+
+// writeCacheMetadata completes entry with the platform and binary and
+// writes it to metaPath
+func writeCacheMetadata(metaPath string, entry CacheEntry, platform ocispec.Platform, manifestDesc, layerDesc ocispec.Descriptor) error {
+	now := time.Now()
+	entry.OS = platform.OS
+	entry.Architecture = platform.Architecture
+	entry.Variant = platform.Variant
+	entry.Digest = manifestDesc.Digest.String()
+	entry.LayerDigest = layerDesc.Digest.String()
+	entry.MediaType = layerDesc.MediaType
+	entry.Size = layerDesc.Size
+	entry.CachedAt = now
+	entry.LastUsed = now
+
+	if data, err := os.ReadFile(metaPath); err == nil {
+		var existing CacheEntry
+		if json.Unmarshal(data, &existing) == nil && existing.LayerDigest == entry.LayerDigest && !existing.CachedAt.IsZero() {
+			entry.CachedAt = existing.CachedAt
+		}
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(metaPath, data, 0644)
+}
+
+// ListCached returns all binaries in the cache, one entry per cached
+// reference and platform. An empty cacheDir uses the default cache directory.
+func ListCached(cacheDir string) ([]CacheEntry, error) {
+	cache, err := NewCache(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	return cache.List()
+}
+
+// Cache manages the local binary cache used by Pull and Run
+type Cache struct {
+	dir string
+}
+
+// NewCache returns a Cache for the given directory.
+// An empty dir uses the default cache directory (~/.cache/bolter).
+func NewCache(dir string) (*Cache, error) {
+	dir, err := getCacheDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Dir returns the directory of the cache
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// List returns all binaries in the cache, one entry per cached reference and
+// platform. Entries whose binary is missing from the cache are skipped.
+func (c *Cache) List() ([]CacheEntry, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	present := entries[:0]
+	for _, entry := range entries {
+		if _, err := os.Stat(entry.Path); err == nil {
+			present = append(present, entry)
+		}
+	}
+
+	return present, nil
+}
+
+// entries reads all per-platform records, including those whose binary
+// is missing
+func (c *Cache) entries() ([]CacheEntry, error) {
+	refsDir := filepath.Join(c.dir, "refs")
+	if _, err := os.Stat(refsDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var entries []CacheEntry
+	err := filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Look for per-platform records
+		if d.IsDir() || filepath.Base(filepath.Dir(path)) != "platforms" || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		var entry CacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil
+		}
+
+		layerDigest := digest.Digest(entry.LayerDigest)
+		if layerDigest.Validate() != nil {
+			return nil
+		}
+
+		entry.Path = getBlobPath(c.dir, layerDigest)
+		entry.recordPath = path
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Reference != entries[j].Reference {
+			return entries[i].Reference < entries[j].Reference
+		}
+		return entries[i].Platform() < entries[j].Platform()
+	})
+
+	return entries, nil
+}
+
+// Size returns the total size in bytes of all content in the cache
+func (c *Cache) Size() (int64, error) {
+	blobs, err := c.blobs()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, size := range blobs {
+		total += size
+	}
+	return total, nil
+}
+
+// blobs returns the size of every blob in the cache by digest
+func (c *Cache) blobs() (map[digest.Digest]int64, error) {
+	blobs := make(map[digest.Digest]int64)
+
+	blobsDir := filepath.Join(c.dir, "blobs")
+	algorithms, err := os.ReadDir(blobsDir)
+	if os.IsNotExist(err) {
+		return blobs, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, algorithm := range algorithms {
+		if !algorithm.IsDir() {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(blobsDir, algorithm.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			// Skip lock and temporary files
+			dgst := digest.NewDigestFromEncoded(digest.Algorithm(algorithm.Name()), file.Name())
+			if file.IsDir() || dgst.Validate() != nil {
+				continue
+			}
+
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+			blobs[dgst] = info.Size()
+		}
+	}
+
+	return blobs, nil
+}
+
+// Remove deletes all cached platforms of ref from the cache. If ref has no
+// tag or digest, every cached reference of the repository is removed.
+// It returns the removed entries.
+func (c *Cache) Remove(ref string) ([]CacheEntry, error) {
+	parsed, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []CacheEntry
+	for _, entry := range entries {
+		if entry.Registry != parsed.Registry || entry.Repository != parsed.Repository {
+			continue
+		}
+		if parsed.Reference != "" && entry.Tag != parsed.Reference {
+			continue
+		}
+		if err := c.removeEntry(entry); err != nil {
+			return removed, err
+		}
+		removed = append(removed, entry)
+	}
+
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("%s is not cached", ref)
+	}
+
+	if _, err := c.collectGarbage(); err != nil {
+		return removed, err
+	}
+
+	return removed, nil
+}
+
+// PruneOptions selects which entries Cache.Prune removes.
+// Zero values disable the corresponding rule.
+type PruneOptions struct {
+	// OlderThan removes entries that were not used within this duration
+	OlderThan time.Duration
+	// KeepLast keeps only the N most recently used tags of each repository
+	KeepLast int
+	// MaxSize evicts the least recently used entries until the cache is
+	// no larger than this many bytes
+	MaxSize int64
+	// DryRun reports what would be removed without removing anything
+	DryRun bool
+}
+
+// PruneResult describes what Cache.Prune removed
+type PruneResult struct {
+	// Removed entries
+	Removed []CacheEntry
+	// Freed bytes of cache content
+	Freed int64
+}
+
+// Prune removes entries from the cache according to opts and deletes
+// content no longer referenced by any remaining entry.
+func (c *Cache) Prune(opts PruneOptions) (*PruneResult, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	// Least recently used first
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].lastUsed().Before(entries[j].lastUsed())
+	})
+
+	evict := make(map[string]bool)
+
+	if opts.OlderThan > 0 {
+		cutoff := time.Now().Add(-opts.OlderThan)
+		for _, entry := range entries {
+			if entry.lastUsed().Before(cutoff) {
+				evict[entry.recordPath] = true
+			}
+		}
+	}
+
+	if opts.KeepLast > 0 {
+		// Tags of each repository, most recently used last
+		tags := make(map[string][]string)
+		for _, entry := range entries {
+			repo := entry.Registry + "/" + entry.Repository
+			list := tags[repo]
+			for i, tag := range list {
+				if tag == entry.Tag {
+					list = append(list[:i], list[i+1:]...)
+					break
+				}
+			}
+			tags[repo] = append(list, entry.Tag)
+		}
+
+		for _, entry := range entries {
+			list := tags[entry.Registry+"/"+entry.Repository]
+			keep := list[max(0, len(list)-opts.KeepLast):]
+			if !slices.Contains(keep, entry.Tag) {
+				evict[entry.recordPath] = true
+			}
+		}
+	}
+
+	if opts.MaxSize > 0 {
+		blobs, err := c.blobs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if c.retainedSize(entries, evict, blobs) <= opts.MaxSize {
+				break
+			}
+			evict[entry.recordPath] = true
+		}
+	}
+
+	result := &PruneResult{}
+	for _, entry := range entries {
+		if !evict[entry.recordPath] {
+			continue
+		}
+		if !opts.DryRun {
+			if err := c.removeEntry(entry); err != nil {
+				return result, err
+			}
+		}
+		result.Removed = append(result.Removed, entry)
+	}
+
+	if opts.DryRun {
+		blobs, err := c.blobs()
+		if err != nil {
+			return nil, err
+		}
+		total, _ := c.Size()
+		result.Freed = total - c.retainedSize(entries, evict, blobs)
+		return result, nil
+	}
+
+	result.Freed, err = c.collectGarbage()
+	return result, err
+}
+
+// retainedSize returns the size of the content referenced by the entries
+// that are not evicted
+func (c *Cache) retainedSize(entries []CacheEntry, evict map[string]bool, blobs map[digest.Digest]int64) int64 {
+	seen := make(map[digest.Digest]bool)
+	var total int64
+	for _, entry := range entries {
+		if evict[entry.recordPath] {
+			continue
+		}
+		for _, dgst := range []digest.Digest{digest.Digest(entry.Digest), digest.Digest(entry.LayerDigest)} {
+			if !seen[dgst] {
+				seen[dgst] = true
+				total += blobs[dgst]
+			}
+		}
+	}
+	return total
+}
+
+// removeEntry deletes the record of entry, and the whole tag directory
+// once no platform of the tag is left
+func (c *Cache) removeEntry(entry CacheEntry) error {
+	if err := os.Remove(entry.recordPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	platformsDir := filepath.Dir(entry.recordPath)
+	if remaining, err := os.ReadDir(platformsDir); err == nil && len(remaining) == 0 {
+		tagDir := filepath.Dir(platformsDir)
+		if err := os.RemoveAll(tagDir); err != nil {
+			return err
+		}
+		removeEmptyParents(filepath.Dir(tagDir), filepath.Join(c.dir, "refs"))
+	}
+
+	return nil
+}
+
+// removeEmptyParents removes dir and its parents up to (excluding) stop for
+// as long as they are empty
+func removeEmptyParents(dir, stop string) {
+	for dir != stop && strings.HasPrefix(dir, stop) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// gcGracePeriod is how long after a blob was written or used garbage
+// collection leaves it alone, even if it is not referenced, because a
+// concurrent pull or run may be about to record it
+const gcGracePeriod = 10 * time.Minute
+
+// lock takes the cache-wide lock that serializes Remove and Prune
+func (c *Cache) lock() (func(), error) {
+	return lockCacheEntry(context.Background(), filepath.Join(c.dir, "gc"))
+}
+
+// collectGarbage deletes all blobs that are not referenced by a cached tag
+// or platform entry and returns the number of bytes freed. Blobs used
+// within gcGracePeriod and blobs being downloaded are kept.
+func (c *Cache) collectGarbage() (int64, error) {
+	referenced := make(map[digest.Digest]bool)
+
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		referenced[digest.Digest(entry.Digest)] = true
+		referenced[digest.Digest(entry.LayerDigest)] = true
+	}
+
+	refsDir := filepath.Join(c.dir, "refs")
+	err = filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || d.Name() != "ref.json" {
+			return nil
+		}
+
+		var cr cacheRef
+		data, err := os.ReadFile(path)
+		if err != nil || json.Unmarshal(data, &cr) != nil {
+			return nil
+		}
+
+		// Tags without any cached platform are dropped with their index.
+		// Version ranges never have platforms and are kept until their
+		// resolved tag expires.
+		isRange := cr.Tag != "" && cr.Digest == ""
+		if _, err := os.Stat(filepath.Join(filepath.Dir(path), "platforms")); os.IsNotExist(err) && (!isRange || time.Now().After(cr.ExpiresAt)) {
+			if err := os.RemoveAll(filepath.Dir(path)); err != nil {
+				return err
+			}
+			removeEmptyParents(filepath.Dir(filepath.Dir(path)), refsDir)
+			return nil
+		}
+
+		if cr.Digest != "" {
+			referenced[digest.Digest(cr.Digest)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	blobs, err := c.blobs()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-gcGracePeriod)
+
+	var freed int64
+	for dgst, size := range blobs {
+		if referenced[dgst] {
+			continue
+		}
+		blobPath := getBlobPath(c.dir, dgst)
+		if info, err := os.Stat(blobPath); err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		unlock, ok := tryLockCacheEntry(blobPath)
+		if !ok {
+			continue
+		}
+		err := os.Remove(blobPath)
+		os.Remove(blobPath + partialSuffix)
+		unlock()
+		os.Remove(blobPath + ".lock")
+		if err != nil && !os.IsNotExist(err) {
+			return freed, err
+		}
+		freed += size
+	}
+
+	return freed, nil
+}
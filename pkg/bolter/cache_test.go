@@ -0,0 +1,456 @@
+package bolter
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry"
+)
+
+// addCacheEntry stores a fake binary for ref in the cache, last used at the given time
+func addCacheEntry(t *testing.T, cacheDir, ref, content string, lastUsed time.Time) {
+	t.Helper()
+
+	parsed, err := registry.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layerDesc := ocispec.Descriptor{Digest: digest.FromString(content), Size: int64(len(content))}
+	manifestDesc := ocispec.Descriptor{Digest: digest.FromString("manifest " + content)}
+
+	blobPath := getBlobPath(cacheDir, layerDesc.Digest)
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(blobPath, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(blobPath, lastUsed, lastUsed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := saveCacheMetadata(cacheDir, parsed, ocispec.Platform{OS: "linux", Architecture: "amd64"}, manifestDesc, layerDesc); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backdate the record
+	cache := &Cache{dir: cacheDir}
+	entries, err := cache.entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Reference == parsed.String() {
+			entry.LastUsed = lastUsed
+			entry.CachedAt = lastUsed
+			if err := os.WriteFile(entry.recordPath, mustMarshal(t, entry), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func cachedReferences(t *testing.T, cache *Cache) []string {
+	t.Helper()
+
+	entries, err := cache.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var refs []string
+	for _, entry := range entries {
+		refs = append(refs, entry.Reference)
+	}
+	return refs
+}
+
+func TestCachePrune(t *testing.T) {
+	now := time.Now()
+
+	setup := func(t *testing.T) *Cache {
+		dir := t.TempDir()
+		addCacheEntry(t, dir, "example.com/app:v1", "binary v1", now.Add(-72*time.Hour))
+		addCacheEntry(t, dir, "example.com/app:v2", "binary v2", now.Add(-48*time.Hour))
+		addCacheEntry(t, dir, "example.com/app:v3", "binary v3 is larger", now.Add(-time.Hour))
+		addCacheEntry(t, dir, "example.com/tool:v1", "tool v1", now.Add(-96*time.Hour))
+		return &Cache{dir: dir}
+	}
+
+	tests := []struct {
+		name string
+		opts PruneOptions
+		want []string
+	}{
+		{
+			name: "older than",
+			opts: PruneOptions{OlderThan: 50 * time.Hour},
+			want: []string{"example.com/app:v2", "example.com/app:v3"},
+		},
+		{
+			name: "keep last",
+			opts: PruneOptions{KeepLast: 1},
+			want: []string{"example.com/app:v3", "example.com/tool:v1"},
+		},
+		{
+			name: "max size",
+			opts: PruneOptions{MaxSize: int64(len("binary v3 is larger") + len("binary v2"))},
+			want: []string{"example.com/app:v2", "example.com/app:v3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := setup(t)
+
+			dryRun := tt.opts
+			dryRun.DryRun = true
+			if _, err := cache.Prune(dryRun); err != nil {
+				t.Fatal(err)
+			}
+			if got := cachedReferences(t, cache); len(got) != 4 {
+				t.Fatalf("dry run removed entries, %d left", len(got))
+			}
+
+			if _, err := cache.Prune(tt.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			got := cachedReferences(t, cache)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+
+			// Binaries of removed entries are garbage collected
+			if _, err := os.Stat(getBlobPath(cache.dir, digest.FromString("tool v1"))); tt.name == "older than" && !os.IsNotExist(err) {
+				t.Fatalf("expected blob of pruned entry to be removed")
+			}
+		})
+	}
+}
+
+func TestCacheRefDirsDoNotCollide(t *testing.T) {
+	dir := t.TempDir()
+
+	refs := []string{
+		"ghcr.io/a/b_c:v1",
+		"ghcr.io/a_b/c:v1",
+		"ghcr.io/a/b:c",
+		"ghcr.io/a/b/c:v1",
+		"localhost:5000/a:v1",
+		"localhost_5000/a:v1",
+		"ghcr.io/a@" + digest.FromString("a").String(),
+		"ghcr.io/a:sha256_" + digest.FromString("a").Encoded(),
+	}
+
+	for _, ref := range refs {
+		parsed, err := registry.ParseReference(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.FromString(ref)}
+		if err := saveCacheRef(dir, parsed, desc, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		addCacheEntry(t, dir, ref, "binary "+ref, time.Now())
+	}
+
+	for _, ref := range refs {
+		parsed, _ := registry.ParseReference(ref)
+		cached, err := loadCacheRef(dir, parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cached.Digest != digest.FromString(ref).String() {
+			t.Errorf("%s resolves to the ref of %s", ref, cached.Reference)
+		}
+	}
+
+	// Removing a repository leaves repositories nested below it alone
+	cache := &Cache{dir: dir}
+	if _, err := cache.Remove("ghcr.io/a/b:c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cachedReferences(t, cache); len(got) != len(refs)-1 {
+		t.Errorf("cached %v after removing ghcr.io/a/b:c", got)
+	}
+}
+
+// pushTestBinary pushes content as the linux/amd64 binary of ref
+func pushTestBinary(t *testing.T, ref, content string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "binary")
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Push(context.Background(), ref, []PlatformBinary{{Path: path, OS: "linux", Architecture: "amd64"}}, PushOptions{Insecure: true}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runBinaryContent fetches ref like Run does and returns the content of the
+// binary it would run
+func runBinaryContent(t *testing.T, ref string, opts RunOptions) string {
+	t.Helper()
+
+	opts.Platform = "linux/amd64"
+	opts.Insecure = true
+	path, err := fetchRunBinary(context.Background(), ref, opts, "Running binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// expireCacheRef makes the cached ref of ref expire
+func expireCacheRef(t *testing.T, cacheDir, ref string) {
+	t.Helper()
+
+	parsed, err := registry.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := loadCacheRef(cacheDir, parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached.ResolvedAt = time.Now().Add(-2 * time.Hour)
+	cached.ExpiresAt = time.Now().Add(-time.Hour)
+	if err := writeCacheRef(cacheDir, parsed, *cached); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheResolveTTL(t *testing.T) {
+	_, host := newTestRegistry(t)
+	cacheDir := t.TempDir()
+	ref := host + "/org/tool:v1"
+	opts := RunOptions{CacheDir: cacheDir}
+
+	pushTestBinary(t, ref, "first build")
+	if got := runBinaryContent(t, ref, opts); got != "first build" {
+		t.Fatalf("ran %q, want first build", got)
+	}
+
+	// The tag moves, but the cached ref has not expired yet
+	pushTestBinary(t, ref, "second build")
+	if got := runBinaryContent(t, ref, opts); got != "first build" {
+		t.Fatalf("ran %q before the ref expired, want first build", got)
+	}
+
+	expireCacheRef(t, cacheDir, ref)
+	if got := runBinaryContent(t, ref, opts); got != "second build" {
+		t.Fatalf("ran %q after the ref expired, want second build", got)
+	}
+}
+
+func TestCacheOfflineFallback(t *testing.T) {
+	reg, host := newTestRegistry(t)
+	cacheDir := t.TempDir()
+	ref := host + "/org/tool:v1"
+	opts := RunOptions{CacheDir: cacheDir}
+
+	pushTestBinary(t, ref, "cached build")
+	runBinaryContent(t, ref, opts)
+
+	// An expired ref is still served when the registry cannot be reached
+	expireCacheRef(t, cacheDir, ref)
+	reg.close()
+	if got := runBinaryContent(t, ref, opts); got != "cached build" {
+		t.Fatalf("ran %q offline, want cached build", got)
+	}
+}
+
+func TestCacheRejectsCorruptBlob(t *testing.T) {
+	reg, host := newTestRegistry(t)
+	cacheDir := t.TempDir()
+	ref := host + "/org/tool:v1"
+	opts := RunOptions{CacheDir: cacheDir}
+
+	pushTestBinary(t, ref, "good build")
+	runBinaryContent(t, ref, opts)
+
+	blobPath := getBlobPath(cacheDir, digest.FromString("good build"))
+	if err := os.WriteFile(blobPath, []byte("evil build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The corrupt blob is downloaded again instead of being run
+	if got := runBinaryContent(t, ref, opts); got != "good build" {
+		t.Fatalf("ran %q, want good build", got)
+	}
+
+	// Without the registry it is refused
+	if err := os.WriteFile(blobPath, []byte("evil build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	reg.close()
+	opts.Platform = "linux/amd64"
+	opts.Insecure = true
+	if _, err := fetchRunBinary(context.Background(), ref, opts, "Running binary"); err == nil {
+		t.Fatal("expected a corrupt blob to be refused without the registry")
+	}
+}
+
+func TestCacheGarbageCollectionKeepsBlobsInUse(t *testing.T) {
+	dir := t.TempDir()
+	cache := &Cache{dir: dir}
+	old := time.Now().Add(-time.Hour)
+
+	writeBlob := func(content string, modified time.Time) string {
+		path := getBlobPath(dir, digest.FromString(content))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	garbage := writeBlob("garbage", old)
+	fresh := writeBlob("just downloaded", time.Now())
+	locked := writeBlob("being downloaded", old)
+
+	unlock, err := lockCacheEntry(context.Background(), locked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	if _, err := cache.collectGarbage(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(garbage); !os.IsNotExist(err) {
+		t.Error("expected unreferenced blob to be removed")
+	}
+	for _, path := range []string{fresh, locked} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be kept: %v", path, err)
+		}
+	}
+}
+
+func TestCacheRecordsLayoutPulls(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	layoutDir := filepath.Join(dir, "layout")
+	writeTestLayout(t, layoutDir, "v1", []byte("binary"))
+
+	_, err := Pull(context.Background(), "oci-layout:"+layoutDir+":v1", PullOptions{UseCache: true, CacheDir: cacheDir, Platform: "linux/amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := &Cache{dir: cacheDir}
+	got := cachedReferences(t, cache)
+	if len(got) != 1 || got[0] != "oci-layout:"+layoutDir+":v1" {
+		t.Fatalf("cached %v", got)
+	}
+
+	entries, _ := cache.List()
+	if err := os.Chtimes(entries[0].Path, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.collectGarbage(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(entries[0].Path); err != nil {
+		t.Errorf("expected recorded layout binary to be kept: %v", err)
+	}
+}
+
+func TestCacheNoCacheRecordsNothing(t *testing.T) {
+	_, host := newTestRegistry(t)
+	cacheDir := t.TempDir()
+	ref := host + "/org/tool:v1"
+
+	pushTestBinary(t, ref, "fresh build")
+	for _, run := range []string{ref, host + "/org/tool@v1"} {
+		if got := runBinaryContent(t, run, RunOptions{CacheDir: cacheDir, NoCache: true}); got != "fresh build" {
+			t.Fatalf("ran %q, want fresh build", got)
+		}
+	}
+
+	if refs := cachedReferences(t, &Cache{dir: cacheDir}); len(refs) != 0 {
+		t.Errorf("run without the cache recorded %v", refs)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "refs")); !os.IsNotExist(err) {
+		t.Errorf("run without the cache saved refs: %v", err)
+	}
+}
+
+func TestCacheGarbageCollectionKeepsRanges(t *testing.T) {
+	reg, host := newTestRegistry(t)
+	cacheDir := t.TempDir()
+	cache := &Cache{dir: cacheDir}
+	ref := host + "/org/tool@^1"
+	opts := RunOptions{CacheDir: cacheDir}
+
+	pushTestBinary(t, host+"/org/tool:v1.0.0", "ranged build")
+	runBinaryContent(t, ref, opts)
+
+	if _, err := cache.collectGarbage(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The range is still resolved from the cache without the registry
+	reg.close()
+	if got := runBinaryContent(t, ref, opts); got != "ranged build" {
+		t.Fatalf("ran %q, want ranged build", got)
+	}
+
+	// Once its tag expired, the range is dropped
+	var rangePath string
+	filepath.WalkDir(filepath.Join(cacheDir, "refs"), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && strings.HasPrefix(d.Name(), "range-") {
+			rangePath = path
+		}
+		return nil
+	})
+	if rangePath == "" {
+		t.Fatal("range was not cached")
+	}
+	data, err := os.ReadFile(filepath.Join(rangePath, "ref.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cr cacheRef
+	if err := json.Unmarshal(data, &cr); err != nil {
+		t.Fatal(err)
+	}
+	cr.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := os.WriteFile(filepath.Join(rangePath, "ref.json"), mustMarshal(t, cr), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cache.collectGarbage(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(rangePath); !os.IsNotExist(err) {
+		t.Errorf("expired range was kept: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package bolter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the user configuration of bolter, kept in ConfigPath:
+//
+//	repositories:
+//	  ghcr.io/org/tool:
+//	    immutable: true
+//	  registry.internal/releases/*:
+//	    immutable: true
+//
+// Repositories are matched by their full name, or by a pattern as
+// understood by path.Match.
+type Config struct {
+	// Repositories configures repositories by name or pattern
+	Repositories map[string]RepositoryConfig `yaml:"repositories"`
+}
+
+// RepositoryConfig configures pushes to a repository
+type RepositoryConfig struct {
+	// Immutable refuses pushes that would move an existing tag
+	Immutable bool `yaml:"immutable"`
+}
+
+// ConfigPath returns the path of the bolter configuration file,
+// ~/.config/bolter/config.yaml on Linux
+func ConfigPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, "bolter", "config.yaml"), nil
+}
+
+// LoadConfig reads the configuration file at file. A missing file yields
+// an empty configuration.
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	for pattern := range config.Repositories {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: invalid repository pattern %q", file, pattern)
+		}
+	}
+
+	return &config, nil
+}
+
+// Repository returns the configuration of the repository of ref. An exact
+// match wins over patterns, and longer patterns over shorter ones.
+func (c *Config) Repository(ref string) RepositoryConfig {
+	parsed, err := parseReference(ref)
+	if err != nil {
+		return RepositoryConfig{}
+	}
+	name := parsed.Registry + "/" + parsed.Repository
+
+	if config, ok := c.Repositories[name]; ok {
+		return config
+	}
+
+	var best string
+	for pattern := range c.Repositories {
+		if matched, _ := path.Match(pattern, name); matched && len(pattern) > len(best) {
+			best = pattern
+		}
+	}
+	return c.Repositories[best]
+}
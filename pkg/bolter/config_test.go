@@ -0,0 +1,42 @@
+package bolter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `repositories:
+  ghcr.io/org/*:
+    immutable: true
+  ghcr.io/org/scratch:
+    immutable: false
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ref, want := range map[string]bool{
+		"ghcr.io/org/tool:v1":         true,
+		"ghcr.io/org/scratch:v1":      false,
+		"ghcr.io/other/tool":          false,
+		"ghcr.io/org/nested/tool:v1":  false,
+		"docker.io/library/tool:v1.0": false,
+	} {
+		if got := config.Repository(ref).Immutable; got != want {
+			t.Errorf("Repository(%q).Immutable = %v, want %v", ref, got, want)
+		}
+	}
+
+	missing, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || len(missing.Repositories) != 0 {
+		t.Errorf("LoadConfig(missing) = %+v, %v", missing, err)
+	}
+}
@@ -0,0 +1,179 @@
+package bolter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+)
+
+// CopyOptions configures the Copy operation
+type CopyOptions struct {
+	// Platforms in format "os/arch" or "os/arch/variant" to copy. The
+	// destination gets a new index listing only these platforms. Empty
+	// copies the index unchanged.
+	Platforms []string
+	// Tags to apply at the destination in addition to the tag of the
+	// destination reference
+	Tags []string
+	// Username for authentication to both registries
+	Username string
+	// Password for authentication to both registries
+	Password string
+	// Insecure allows insecure registry connections
+	Insecure bool
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+	// Prerelease allows version ranges to resolve to pre-release tags
+	Prerelease bool
+	// Concurrency is the number of blobs copied at the same time.
+	// Zero uses DefaultConcurrency.
+	Concurrency int
+}
+
+// Copy copies the index or manifest referenced by src, with every platform
+// manifest and blob it refers to, to the repository of dst, and tags it as
+// the tag of dst and opts.Tags. If dst has no tag and no tags are given, the
+// tag of src is used. Content is copied unchanged, so digests stay the same
+// unless opts.Platforms selects platforms into a new index. Blobs are
+// mounted instead of copied when both repositories are on the same
+// registry. It returns the descriptor of the copied index.
+func Copy(ctx context.Context, src, dst string, opts CopyOptions) (_ ocispec.Descriptor, err error) {
+	defer func() { err = classifyError(err) }()
+
+	log := logger(opts.Logger)
+
+	base, rng, isRange := splitVersionRange(src)
+	if isRange {
+		src = base
+	}
+
+	srcRepo, err := createRepository(src, opts.Insecure)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to create source repository: %w", err)
+	}
+	if err := setupAuth(srcRepo, opts.Username, opts.Password, log); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	dstRepo, err := createRepository(dst, opts.Insecure)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to create destination repository: %w", err)
+	}
+	if err := setupAuth(dstRepo, opts.Username, opts.Password, log); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	if isRange {
+		tag, err := resolveVersionRange(ctx, srcRepo, rng, opts.Prerelease)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to resolve version range: %w", err)
+		}
+		srcRepo.Reference.Reference = tag
+	}
+
+	var tags []string
+	if dstRepo.Reference.Reference != "" && !isDigestReference(dstRepo.Reference) {
+		tags = append(tags, dstRepo.Reference.Reference)
+	}
+	tags = append(tags, opts.Tags...)
+	if len(tags) == 0 && !isDigestReference(srcRepo.Reference) {
+		tags = append(tags, srcRepo.Reference.Reference)
+	}
+
+	desc, err := srcRepo.Resolve(ctx, srcRepo.Reference.Reference)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to resolve reference: %w", err)
+	}
+
+	copyOpts := oras.DefaultCopyGraphOptions
+	copyOpts.Concurrency = opts.Concurrency
+	if copyOpts.Concurrency <= 0 {
+		copyOpts.Concurrency = DefaultConcurrency
+	}
+	if srcRepo.Reference.Registry == dstRepo.Reference.Registry && srcRepo.Reference.Repository != dstRepo.Reference.Repository {
+		copyOpts.MountFrom = func(ctx context.Context, desc ocispec.Descriptor) ([]string, error) {
+			return []string{srcRepo.Reference.Repository}, nil
+		}
+		copyOpts.OnMounted = func(ctx context.Context, desc ocispec.Descriptor) error {
+			log.Debug("Mounted blob", "digest", desc.Digest, "from", srcRepo.Reference.Repository)
+			return nil
+		}
+	}
+
+	log.Info("Copying", "src", srcRepo.Reference, "dst", dstRepo.Reference.Registry+"/"+dstRepo.Reference.Repository, "digest", desc.Digest, "tags", tags)
+
+	if len(opts.Platforms) > 0 {
+		desc, err = copyFilteredIndex(ctx, srcRepo, dstRepo, desc, opts.Platforms, copyOpts, log)
+	} else {
+		err = oras.CopyGraph(ctx, srcRepo, dstRepo, desc, copyOpts)
+	}
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to copy: %w", err)
+	}
+
+	for _, tag := range tags {
+		if err := dstRepo.Tag(ctx, desc, tag); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to tag %s: %w", tag, err)
+		}
+	}
+
+	log.Info("Copied", "dst", dstRepo.Reference.Registry+"/"+dstRepo.Reference.Repository, "digest", desc.Digest, "tags", tags)
+
+	return desc, nil
+}
+
+// copyFilteredIndex copies the manifests of the index described by desc that
+// match platforms from src to dst, along with a new index listing only those.
+// Platforms must match exactly: no other variant is substituted, so the
+// result does not depend on the machine running the copy.
+// It returns the descriptor of the new index.
+func copyFilteredIndex(ctx context.Context, src content.ReadOnlyStorage, dst content.Storage, desc ocispec.Descriptor, platforms []string, opts oras.CopyGraphOptions, log *slog.Logger) (ocispec.Descriptor, error) {
+	if desc.MediaType != ocispec.MediaTypeImageIndex {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: platforms can only be selected from an index, not %s", ErrUnsupportedMediaType, desc.MediaType)
+	}
+
+	data, err := content.FetchAll(ctx, src, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	var manifests []ocispec.Descriptor
+	seen := make(map[digest.Digest]bool)
+	for _, platform := range platforms {
+		matches, err := findExactManifests(index, parsePlatform(platform))
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		for _, manifestDesc := range matches {
+			if seen[manifestDesc.Digest] {
+				continue
+			}
+			seen[manifestDesc.Digest] = true
+
+			log.Debug("Copying platform", "platform", platformString(*manifestDesc.Platform), "digest", manifestDesc.Digest)
+			if err := oras.CopyGraph(ctx, src, dst, manifestDesc, opts); err != nil {
+				return ocispec.Descriptor{}, err
+			}
+			manifests = append(manifests, manifestDesc)
+		}
+	}
+
+	index.Manifests = manifests
+	indexBytes, err := json.Marshal(index)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return oras.PushBytes(ctx, dst, ocispec.MediaTypeImageIndex, indexBytes)
+}
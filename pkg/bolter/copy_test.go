@@ -0,0 +1,107 @@
+package bolter
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	reg, host := newTestRegistry(t)
+	otherReg, otherHost := newTestRegistry(t)
+
+	layoutDir := filepath.Join(t.TempDir(), "layout")
+	indexDesc := writeTestLayout(t, layoutDir, "v1", []byte("\x7fELF not really"))
+	if _, err := Load(ctx, layoutDir, host+"/org/tool", LoadOptions{Insecure: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("same registry", func(t *testing.T) {
+		desc, err := Copy(ctx, host+"/org/tool:v1", host+"/org/release:1.0", CopyOptions{Tags: []string{"latest"}, Insecure: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if desc.Digest != indexDesc.Digest {
+			t.Errorf("copied %s, want %s", desc.Digest, indexDesc.Digest)
+		}
+		for _, tag := range []string{"1.0", "latest"} {
+			if got := reg.tag("org/release", tag); got != indexDesc.Digest.String() {
+				t.Errorf("tag %s = %s, want %s", tag, got, indexDesc.Digest)
+			}
+		}
+		if reg.mountCount() == 0 {
+			t.Error("expected blobs to be mounted")
+		}
+	})
+
+	t.Run("other registry keeps tag", func(t *testing.T) {
+		if _, err := Copy(ctx, host+"/org/tool:v1", otherHost+"/org/tool", CopyOptions{Insecure: true}); err != nil {
+			t.Fatal(err)
+		}
+		if got := otherReg.tag("org/tool", "v1"); got != indexDesc.Digest.String() {
+			t.Errorf("tag v1 = %s, want %s", got, indexDesc.Digest)
+		}
+	})
+
+	t.Run("platform filter", func(t *testing.T) {
+		desc, err := Copy(ctx, host+"/org/tool:v1", otherHost+"/org/filtered:v1", CopyOptions{Platforms: []string{"linux/amd64"}, Insecure: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := otherReg.tag("org/filtered", "v1"); got != desc.Digest.String() {
+			t.Errorf("tag v1 = %s, want %s", got, desc.Digest)
+		}
+		if _, err := Pull(ctx, otherHost+"/org/filtered:v1", PullOptions{Output: filepath.Join(t.TempDir(), "tool"), Platform: "linux/amd64", Insecure: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestCopyPlatformsExact(t *testing.T) {
+	ctx := context.Background()
+	_, host := newTestRegistry(t)
+
+	layoutDir := filepath.Join(t.TempDir(), "layout")
+	writeTestLayoutPlatforms(t, layoutDir, "v1", map[string][]byte{
+		"linux/amd64":    []byte("baseline"),
+		"linux/amd64/v3": []byte("v3"),
+		"linux/arm/v6":   []byte("armv6"),
+	})
+	if _, err := Load(ctx, layoutDir, host+"/org/tool", LoadOptions{Insecure: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		platforms []string
+		want      []string
+	}{
+		{platforms: []string{"linux/amd64"}, want: []string{"linux/amd64"}},
+		{platforms: []string{"linux/amd64/v3"}, want: []string{"linux/amd64/v3"}},
+		{platforms: []string{"linux/x86_64", "linux/amd64/v3"}, want: []string{"linux/amd64", "linux/amd64/v3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.platforms, ","), func(t *testing.T) {
+			desc, err := Copy(ctx, host+"/org/tool:v1", host+"/org/filtered:v1", CopyOptions{Platforms: tt.platforms, Insecure: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo, err := createRepository(host+"/org/filtered", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := indexPlatforms(t, repo, desc); !slices.Equal(got, tt.want) {
+				t.Errorf("copied %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	_, err := Copy(ctx, host+"/org/tool:v1", host+"/org/filtered:arm", CopyOptions{Platforms: []string{"linux/arm/v7"}, Insecure: true})
+	if !errors.Is(err, ErrPlatformNotFound) {
+		t.Errorf("expected ErrPlatformNotFound, got %v", err)
+	}
+}
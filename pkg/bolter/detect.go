@@ -0,0 +1,240 @@
+package bolter
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// Binary formats recognised by DetectPlatform
+const (
+	FormatELF   = "elf"
+	FormatMachO = "macho"
+	FormatPE    = "pe"
+	FormatWasm  = "wasm"
+)
+
+// DetectedPlatform is the platform of a binary as read from its headers
+type DetectedPlatform struct {
+	// Format of the binary (FormatELF, FormatMachO, FormatPE or FormatWasm),
+	// or empty if it was not recognised
+	Format string
+	// OS of the binary, or empty if the headers do not tell
+	// (e.g., most ELF binaries, Mach-O binaries, WASM modules)
+	OS string
+	// Architecture of the binary, or empty if the headers do not tell
+	// (e.g., universal Mach-O binaries)
+	Architecture string
+}
+
+// osFormats lists the operating systems that use each binary format
+var osFormats = map[string][]string{
+	FormatELF:   {"linux", "android", "freebsd", "openbsd", "netbsd", "dragonfly", "solaris", "illumos"},
+	FormatMachO: {"darwin", "ios"},
+	FormatPE:    {"windows"},
+	FormatWasm:  {"js", "wasip1"},
+}
+
+// defaultOS is assumed for a format when neither the headers nor the user
+// name the operating system
+var defaultOS = map[string]string{
+	FormatELF:   "linux",
+	FormatMachO: "darwin",
+	FormatPE:    "windows",
+	FormatWasm:  "wasip1",
+}
+
+// DetectPlatform reads the headers of the binary at path to determine its
+// format, operating system and architecture
+func DetectPlatform(path string) (*DetectedPlatform, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return &DetectedPlatform{}, nil
+		}
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte(elf.ELFMAG)):
+		return detectELF(f)
+	case bytes.HasPrefix(magic, []byte("MZ")):
+		return detectPE(f)
+	case bytes.Equal(magic, []byte("\x00asm")):
+		return &DetectedPlatform{Format: FormatWasm, Architecture: "wasm"}, nil
+	}
+
+	switch magicNumber := uint32(magic[0])<<24 | uint32(magic[1])<<16 | uint32(magic[2])<<8 | uint32(magic[3]); magicNumber {
+	case macho.Magic32, macho.Magic64, 0xcefaedfe, 0xcffaedfe:
+		return detectMachO(f)
+	case macho.MagicFat:
+		// Universal binaries contain several architectures
+		return &DetectedPlatform{Format: FormatMachO}, nil
+	}
+
+	return &DetectedPlatform{}, nil
+}
+
+func detectELF(r io.ReaderAt) (*DetectedPlatform, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ELF binary: %w", err)
+	}
+	defer f.Close()
+
+	detected := &DetectedPlatform{Format: FormatELF}
+
+	switch f.OSABI {
+	case elf.ELFOSABI_LINUX:
+		detected.OS = "linux"
+	case elf.ELFOSABI_FREEBSD:
+		detected.OS = "freebsd"
+	case elf.ELFOSABI_NETBSD:
+		detected.OS = "netbsd"
+	case elf.ELFOSABI_OPENBSD:
+		detected.OS = "openbsd"
+	case elf.ELFOSABI_SOLARIS:
+		detected.OS = "solaris"
+	}
+	// Go marks NetBSD and OpenBSD binaries with a note rather than the ABI
+	if f.Section(".note.netbsd.ident") != nil {
+		detected.OS = "netbsd"
+	}
+	if f.Section(".note.openbsd.ident") != nil {
+		detected.OS = "openbsd"
+	}
+
+	is64 := f.Class == elf.ELFCLASS64
+	littleEndian := f.Data == elf.ELFDATA2LSB
+
+	switch f.Machine {
+	case elf.EM_X86_64:
+		detected.Architecture = "amd64"
+	case elf.EM_386:
+		detected.Architecture = "386"
+	case elf.EM_AARCH64:
+		detected.Architecture = "arm64"
+	case elf.EM_ARM:
+		detected.Architecture = "arm"
+	case elf.EM_RISCV:
+		if is64 {
+			detected.Architecture = "riscv64"
+		}
+	case elf.EM_PPC64:
+		detected.Architecture = "ppc64"
+		if littleEndian {
+			detected.Architecture = "ppc64le"
+		}
+	case elf.EM_S390:
+		detected.Architecture = "s390x"
+	case elf.EM_LOONGARCH:
+		detected.Architecture = "loong64"
+	case elf.EM_MIPS:
+		switch {
+		case is64 && littleEndian:
+			detected.Architecture = "mips64le"
+		case is64:
+			detected.Architecture = "mips64"
+		case littleEndian:
+			detected.Architecture = "mipsle"
+		default:
+			detected.Architecture = "mips"
+		}
+	}
+
+	return detected, nil
+}
+
+func detectMachO(r io.ReaderAt) (*DetectedPlatform, error) {
+	f, err := macho.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Mach-O binary: %w", err)
+	}
+	defer f.Close()
+
+	// Mach-O is used by darwin and ios alike, the headers do not tell
+	detected := &DetectedPlatform{Format: FormatMachO}
+
+	switch f.Cpu {
+	case macho.CpuAmd64:
+		detected.Architecture = "amd64"
+	case macho.CpuArm64:
+		detected.Architecture = "arm64"
+	case macho.Cpu386:
+		detected.Architecture = "386"
+	case macho.CpuArm:
+		detected.Architecture = "arm"
+	}
+
+	return detected, nil
+}
+
+func detectPE(r io.ReaderAt) (*DetectedPlatform, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		// An MZ header alone does not make a PE binary
+		return &DetectedPlatform{}, nil
+	}
+	defer f.Close()
+
+	detected := &DetectedPlatform{Format: FormatPE, OS: "windows"}
+
+	switch f.Machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		detected.Architecture = "amd64"
+	case pe.IMAGE_FILE_MACHINE_I386:
+		detected.Architecture = "386"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		detected.Architecture = "arm64"
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		detected.Architecture = "arm"
+	}
+
+	return detected, nil
+}
+
+// resolvePlatform fills in the platform of binary from its headers and
+// checks that a declared platform agrees with them
+func resolvePlatform(binary PlatformBinary, detected *DetectedPlatform) (PlatformBinary, error) {
+	declared := normalizePlatform(binary.platform())
+	binary.OS, binary.Architecture, binary.Variant = declared.OS, declared.Architecture, declared.Variant
+
+	if binary.OS == "" {
+		binary.OS = detected.OS
+		if binary.OS == "" {
+			binary.OS = defaultOS[detected.Format]
+		}
+	}
+	if binary.Architecture == "" {
+		binary.Architecture = detected.Architecture
+	}
+
+	if binary.OS == "" || binary.Architecture == "" {
+		return binary, fmt.Errorf("cannot detect the platform of %s, declare it as os/arch=path", binary.Path)
+	}
+
+	mismatch := (detected.OS != "" && detected.OS != binary.OS) ||
+		(detected.Architecture != "" && detected.Architecture != binary.Architecture) ||
+		(detected.Format != "" && !slices.Contains(osFormats[detected.Format], binary.OS))
+	if mismatch {
+		return binary, &PlatformMismatchError{
+			Path:     binary.Path,
+			Declared: platformString(binary.platform()),
+			Detected: detected,
+		}
+	}
+
+	return binary, nil
+}
@@ -0,0 +1,97 @@
+package bolter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	detected, err := DetectPlatform(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detected.Architecture != runtime.GOARCH {
+		t.Errorf("Architecture = %q, want %q", detected.Architecture, runtime.GOARCH)
+	}
+	if detected.Format == "" {
+		t.Error("format of the test binary was not detected")
+	}
+
+	dir := t.TempDir()
+	for name, tc := range map[string]struct {
+		content string
+		want    DetectedPlatform
+	}{
+		"wasm":   {"\x00asm\x01\x00\x00\x00", DetectedPlatform{Format: FormatWasm, Architecture: "wasm"}},
+		"script": {"#!/bin/sh\necho hi\n", DetectedPlatform{}},
+		"empty":  {"", DetectedPlatform{}},
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(tc.content), 0755); err != nil {
+			t.Fatal(err)
+		}
+		detected, err := DetectPlatform(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if *detected != tc.want {
+			t.Errorf("%s: detected %+v, want %+v", name, *detected, tc.want)
+		}
+	}
+}
+
+func TestResolvePlatform(t *testing.T) {
+	elfAmd64 := &DetectedPlatform{Format: FormatELF, Architecture: "amd64"}
+	machoArm64 := &DetectedPlatform{Format: FormatMachO, Architecture: "arm64"}
+	wasm := &DetectedPlatform{Format: FormatWasm, Architecture: "wasm"}
+
+	tests := []struct {
+		name     string
+		binary   PlatformBinary
+		detected *DetectedPlatform
+		want     string
+		mismatch bool
+	}{
+		{"detect elf", PlatformBinary{}, elfAmd64, "linux/amd64", false},
+		{"declared elf os", PlatformBinary{OS: "freebsd", Architecture: "amd64"}, elfAmd64, "freebsd/amd64", false},
+		{"detect macho", PlatformBinary{}, machoArm64, "darwin/arm64", false},
+		{"declared ios", PlatformBinary{OS: "ios", Architecture: "arm64"}, machoArm64, "ios/arm64", false},
+		{"detect wasm", PlatformBinary{}, wasm, "wasip1/wasm", false},
+		{"declared js", PlatformBinary{OS: "js", Architecture: "wasm"}, wasm, "js/wasm", false},
+		{"unknown declared", PlatformBinary{OS: "plan9", Architecture: "386"}, &DetectedPlatform{}, "plan9/386", false},
+		{"macho as linux", PlatformBinary{OS: "linux", Architecture: "amd64"}, machoArm64, "", true},
+		{"wrong arch", PlatformBinary{OS: "linux", Architecture: "arm64"}, elfAmd64, "", true},
+		{"elf as windows", PlatformBinary{OS: "windows", Architecture: "amd64"}, elfAmd64, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolvePlatform(tt.binary, tt.detected)
+			var mismatch *PlatformMismatchError
+			if errors.As(err, &mismatch) != tt.mismatch {
+				t.Fatalf("err = %v, want mismatch %v", err, tt.mismatch)
+			}
+			if tt.mismatch {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if platform := got.OS + "/" + got.Architecture; platform != tt.want {
+				t.Errorf("platform = %s, want %s", platform, tt.want)
+			}
+		})
+	}
+
+	if _, err := resolvePlatform(PlatformBinary{Path: "script"}, &DetectedPlatform{}); err == nil {
+		t.Error("expected error for undetectable binary without declared platform")
+	}
+}
@@ -0,0 +1,196 @@
+package bolter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// partialSuffix is appended to the output path of a download in progress
+const partialSuffix = ".partial"
+
+// downloadBlob downloads the blob described by desc to output, verifying it
+// against the digest and size in the descriptor. The download is written to
+// output.partial, which is kept when the download is interrupted and resumed
+// with a range request by the next call. Only the verified binary is renamed
+// to output, so nobody can execute a partially written one.
+func downloadBlob(ctx context.Context, src content.Fetcher, desc ocispec.Descriptor, output string, progress *progressReporter) error {
+	if err := desc.Digest.Validate(); err != nil {
+		return fmt.Errorf("invalid layer digest: %w", err)
+	}
+
+	outputDir := filepath.Dir(output)
+	if outputDir != "." && outputDir != "" {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return err
+		}
+	}
+
+	partialPath := output + partialSuffix
+	f, err := os.OpenFile(partialPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	// Concurrent downloads to the same output take turns
+	if err := lockFile(ctx, f); err != nil {
+		f.Close()
+		return err
+	}
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if offset > desc.Size {
+		if err := f.Truncate(0); err != nil {
+			f.Close()
+			return err
+		}
+		offset = 0
+	}
+
+	if offset < desc.Size {
+		if err := resumeDownload(ctx, src, desc, f, offset, progress); err != nil {
+			f.Close()
+			progress.send(EventError, 0, err)
+			return err
+		}
+	}
+
+	// Verify the whole file, including what earlier attempts downloaded
+	_, err = f.Seek(0, io.SeekStart)
+	if err == nil {
+		err = copyVerified(io.Discard, f, desc)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partialPath)
+		// The part kept from an earlier attempt may have been corrupted
+		var mismatch *DigestMismatchError
+		if offset > 0 && errors.As(err, &mismatch) {
+			return downloadBlob(ctx, src, desc, output, progress)
+		}
+		progress.send(EventError, 0, err)
+		return err
+	}
+
+	if err := os.Chmod(partialPath, 0755); err != nil {
+		os.Remove(partialPath)
+		return fmt.Errorf("failed to make binary executable: %w", err)
+	}
+
+	if err := os.Rename(partialPath, output); err != nil {
+		return err
+	}
+
+	progress.send(EventDone, desc.Size, nil)
+	return nil
+}
+
+// resumeDownload appends the blob described by desc to f, which already
+// holds its first offset bytes. If src is not a registry or the registry
+// does not honour the range request, f is truncated and the whole blob is
+// downloaded again.
+func resumeDownload(ctx context.Context, src content.Fetcher, desc ocispec.Descriptor, f *os.File, offset int64, progress *progressReporter) error {
+	var body io.ReadCloser
+	var start int64
+	var err error
+	if repo, ok := src.(*remote.Repository); ok {
+		body, start, err = fetchBlobRange(ctx, repo, desc, offset)
+	} else {
+		body, err = src.Fetch(ctx, desc)
+	}
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if start != offset {
+		if err := f.Truncate(start); err != nil {
+			return err
+		}
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	progress.send(EventStart, start, nil)
+
+	// Read one byte past the expected size so oversized content is detected
+	_, err = io.Copy(f, progress.reader(io.LimitReader(body, desc.Size-start+1), start))
+	return err
+}
+
+// fetchBlobRange fetches the blob described by desc from offset on, and
+// returns its content along with the offset it actually starts at, which
+// is zero if the registry ignored the range.
+func fetchBlobRange(ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor, offset int64) (io.ReadCloser, int64, error) {
+	client := repo.Client
+	if client == nil {
+		client = auth.DefaultClient
+	}
+
+	scheme := "https"
+	if repo.PlainHTTP {
+		scheme = "http"
+	}
+	ref := repo.Reference
+	ref.Reference = desc.Digest.String()
+	ctx = auth.AppendRepositoryScope(ctx, ref, auth.ActionPull)
+	u := &url.URL{Scheme: scheme, Host: ref.Host(), Path: "/v2/" + ref.Repository + "/blobs/" + desc.Digest.String()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset > 0 {
+		// Some registries only understand ranges with an explicit end
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, desc.Size-1))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if resp.ContentLength != -1 && resp.ContentLength != desc.Size {
+			resp.Body.Close()
+			return nil, 0, fmt.Errorf("%s %q: mismatch Content-Length", req.Method, req.URL)
+		}
+		return resp.Body, 0, nil
+	case http.StatusPartialContent:
+		var start, end, size int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &size); err != nil || start != offset || size != desc.Size {
+			resp.Body.Close()
+			return nil, 0, fmt.Errorf("%s %q: unexpected Content-Range %q", req.Method, req.URL, resp.Header.Get("Content-Range"))
+		}
+		return resp.Body, start, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial download does not belong to this blob, start over
+		resp.Body.Close()
+		return fetchBlobRange(ctx, repo, desc, 0)
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("%s: %w", desc.Digest, errdef.ErrNotFound)
+	default:
+		defer resp.Body.Close()
+		return nil, 0, parseErrorResponse(resp)
+	}
+}
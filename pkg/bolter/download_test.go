@@ -0,0 +1,116 @@
+package bolter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+func TestDownloadBlobResume(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	dgst := digest.FromBytes(data)
+
+	var mu sync.Mutex
+	var ranges []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/test/blobs/"+dgst.String() {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	repo, err := createRepository(strings.TrimPrefix(server.URL, "http://")+"/test:latest", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := ocispec.Descriptor{MediaType: "application/octet-stream", Digest: dgst, Size: int64(len(data))}
+
+	tests := []struct {
+		name       string
+		partial    []byte
+		wantRanges []string
+	}{
+		{"fresh", nil, []string{""}},
+		{"resume", data[:4000], []string{"bytes=4000-9999"}},
+		{"complete", data, nil},
+		{"corrupt", bytes.Repeat([]byte("x"), 4000), []string{"bytes=4000-9999", ""}},
+		{"oversized", append(bytes.Clone(data), 'x'), []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "binary")
+			if tt.partial != nil {
+				if err := os.WriteFile(output+partialSuffix, tt.partial, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ranges = nil
+
+			var events []Event
+			progress := newProgressReporter(func(e Event) { events = append(events, e) }, "linux/amd64", dgst, desc.Size)
+			if err := downloadBlob(context.Background(), repo, desc, output, progress); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("downloaded %d bytes, want %d", len(got), len(data))
+			}
+			if _, err := os.Stat(output + partialSuffix); !os.IsNotExist(err) {
+				t.Errorf("partial file was not removed: %v", err)
+			}
+			if strings.Join(ranges, ",") != strings.Join(tt.wantRanges, ",") {
+				t.Errorf("ranges = %q, want %q", ranges, tt.wantRanges)
+			}
+			if len(events) == 0 || events[len(events)-1].Type != EventDone {
+				t.Errorf("events = %+v, want them to end with EventDone", events)
+			}
+		})
+	}
+}
+
+func TestDownloadBlobUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED","message":"authentication required"}]}`))
+	}))
+	defer server.Close()
+
+	repo, err := createRepository(strings.TrimPrefix(server.URL, "http://")+"/test:latest", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("binary")
+	desc := ocispec.Descriptor{MediaType: "application/octet-stream", Digest: digest.FromBytes(data), Size: int64(len(data))}
+
+	err = downloadBlob(context.Background(), repo, desc, filepath.Join(t.TempDir(), "binary"), nil)
+	var resp *errcode.ErrorResponse
+	if !errors.As(err, &resp) || resp.StatusCode != http.StatusUnauthorized || len(resp.Errors) != 1 {
+		t.Fatalf("expected an error response with status 401, got %v", err)
+	}
+	if !errors.Is(classifyError(err), ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", classifyError(err))
+	}
+}
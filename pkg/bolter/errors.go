@@ -0,0 +1,198 @@
+package bolter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+var (
+	// ErrNotFound is returned when a repository, tag, manifest or blob does
+	// not exist in the registry. It is the same error as errdef.ErrNotFound
+	// of oras-go.
+	ErrNotFound = errdef.ErrNotFound
+	// ErrUnauthorized is returned when the registry rejects the credentials,
+	// or requires credentials and none were found
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrPlatformNotFound is matched by a *PlatformNotFoundError
+	ErrPlatformNotFound = errors.New("platform not found")
+	// ErrDigestMismatch is matched by a *DigestMismatchError
+	ErrDigestMismatch = errors.New("digest mismatch")
+	// ErrUnsupportedMediaType is returned when a reference points to content
+	// that is neither a manifest nor an index
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrImmutableTag is matched by an *ImmutableTagError
+	ErrImmutableTag = errors.New("immutable tag")
+)
+
+// DigestMismatchError is returned when downloaded or cached content does not
+// match the digest or size it was expected to have
+type DigestMismatchError struct {
+	// Expected digest from the manifest or cache metadata
+	Expected digest.Digest
+	// Actual digest of the received content
+	Actual digest.Digest
+	// ExpectedSize in bytes, or -1 if unknown
+	ExpectedSize int64
+	// ActualSize in bytes of the received content
+	ActualSize int64
+}
+
+func (e *DigestMismatchError) Error() string {
+	if e.ExpectedSize >= 0 && e.ExpectedSize != e.ActualSize {
+		return fmt.Sprintf("size mismatch: expected %d bytes, got %d bytes", e.ExpectedSize, e.ActualSize)
+	}
+	return fmt.Sprintf("digest mismatch: expected %s, got %s", e.Expected, e.Actual)
+}
+
+// Is makes the error match ErrDigestMismatch
+func (e *DigestMismatchError) Is(target error) bool {
+	return target == ErrDigestMismatch
+}
+
+// PlatformNotFoundError is returned when an index has no binary for the
+// requested platform
+type PlatformNotFoundError struct {
+	// Platform that was requested
+	Platform ocispec.Platform
+	// Available platforms in the index
+	Available []ocispec.Platform
+}
+
+func (e *PlatformNotFoundError) Error() string {
+	available := make([]string, len(e.Available))
+	for i, p := range e.Available {
+		available[i] = platformString(p)
+	}
+	return fmt.Sprintf("no manifest found for %s (available: %s)", platformString(e.Platform), strings.Join(available, ", "))
+}
+
+// Is makes the error match ErrPlatformNotFound
+func (e *PlatformNotFoundError) Is(target error) bool {
+	return target == ErrPlatformNotFound
+}
+
+// ExitError is returned by Run when the binary exits with a non-zero code
+// or is killed by a signal
+type ExitError struct {
+	// Code the binary exited with, or 128 plus the signal number if it was
+	// killed by a signal
+	Code int
+	// Signal that killed the binary, if any
+	Signal os.Signal
+}
+
+func (e *ExitError) Error() string {
+	if e.Signal != nil {
+		return fmt.Sprintf("binary was killed by signal %v", e.Signal)
+	}
+	return fmt.Sprintf("binary exited with code %d", e.Code)
+}
+
+// PlatformMismatchError is returned by Push when the declared platform of a
+// binary does not agree with its headers
+type PlatformMismatchError struct {
+	// Path of the binary
+	Path string
+	// Declared platform in format "os/arch"
+	Declared string
+	// Detected platform from the binary headers
+	Detected *DetectedPlatform
+}
+
+func (e *PlatformMismatchError) Error() string {
+	detected := e.Detected.Format
+	if e.Detected.OS != "" || e.Detected.Architecture != "" {
+		detected = fmt.Sprintf("%s %s/%s", detected, orUnknown(e.Detected.OS), orUnknown(e.Detected.Architecture))
+	}
+	return fmt.Sprintf("%s is declared as %s but its headers say %s", e.Path, e.Declared, detected)
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "?"
+	}
+	return s
+}
+
+// ImmutableTagError is returned by Push when an immutable push would move a
+// tag that already exists to a different digest
+type ImmutableTagError struct {
+	// Tag that already exists
+	Tag string
+	// Old digest the tag points to
+	Old digest.Digest
+	// New digest the push would have tagged
+	New digest.Digest
+}
+
+func (e *ImmutableTagError) Error() string {
+	return fmt.Sprintf("tag %s is immutable: it points to %s, refusing to overwrite it with %s", e.Tag, e.Old, e.New)
+}
+
+func (e *ImmutableTagError) Is(target error) bool {
+	return target == ErrImmutableTag
+}
+
+// registryError attaches one of the sentinel errors to an error response of
+// the registry
+type registryError struct {
+	sentinel error
+	err      error
+}
+
+func (e *registryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *registryError) Unwrap() []error {
+	return []error{e.sentinel, e.err}
+}
+
+// classifyError makes registry error responses match ErrUnauthorized or
+// ErrNotFound, so that callers do not have to inspect status codes
+func classifyError(err error) error {
+	var resp *errcode.ErrorResponse
+	if !errors.As(err, &resp) {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &registryError{sentinel: ErrUnauthorized, err: err}
+	case http.StatusNotFound:
+		if !errors.Is(err, ErrNotFound) {
+			return &registryError{sentinel: ErrNotFound, err: err}
+		}
+	}
+	return err
+}
+
+// parseErrorResponse turns a failed response of the registry into an
+// *errcode.ErrorResponse like the ones oras-go returns, so that
+// classifyError recognizes it
+func parseErrorResponse(resp *http.Response) error {
+	errResp := &errcode.ErrorResponse{
+		Method:     resp.Request.Method,
+		URL:        resp.Request.URL,
+		StatusCode: resp.StatusCode,
+	}
+
+	var body struct {
+		Errors errcode.Errors `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body); err == nil {
+		errResp.Errors = body.Errors
+	}
+
+	return errResp
+}
@@ -0,0 +1,47 @@
+package bolter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+func TestClassifyError(t *testing.T) {
+	response := func(status int) error {
+		return fmt.Errorf("failed to resolve reference: %w", &errcode.ErrorResponse{Method: http.MethodGet, StatusCode: status})
+	}
+
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{response(http.StatusUnauthorized), ErrUnauthorized},
+		{response(http.StatusForbidden), ErrUnauthorized},
+		{response(http.StatusNotFound), ErrNotFound},
+		{fmt.Errorf("tag: %w", ErrNotFound), ErrNotFound},
+		{&DigestMismatchError{ExpectedSize: -1}, ErrDigestMismatch},
+		{&PlatformNotFoundError{}, ErrPlatformNotFound},
+		{&ImmutableTagError{Tag: "v1"}, ErrImmutableTag},
+	}
+
+	for _, tt := range tests {
+		err := classifyError(tt.err)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("classifyError(%v) does not match %v", tt.err, tt.want)
+		}
+		if err.Error() != tt.err.Error() {
+			t.Errorf("classifyError(%v) changed the message to %q", tt.err, err)
+		}
+	}
+
+	var resp *errcode.ErrorResponse
+	if !errors.As(classifyError(response(http.StatusUnauthorized)), &resp) || resp.StatusCode != http.StatusUnauthorized {
+		t.Error("classified error does not unwrap to the registry response")
+	}
+	if classifyError(nil) != nil {
+		t.Error("classifyError(nil) != nil")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
 
 	"github.com/aep/bolter/pkg/bolter"
 )
@@ -15,7 +17,7 @@ func ExamplePull() {
 	opts := bolter.PullOptions{
 		Output:   "./myapp",
 		Platform: "linux/amd64",
-		Verbose:  true,
+		Logger:   slog.Default(),
 		UseCache: true,
 	}
 
@@ -35,7 +37,7 @@ func ExampleRun() {
 
 	opts := bolter.RunOptions{
 		Platform: "linux/amd64",
-		Verbose:  true,
+		Logger:   slog.Default(),
 		UseExec:  false, // Use exec.Command instead of syscall.Exec
 	}
 
@@ -46,6 +48,28 @@ func ExampleRun() {
 	}
 }
 
+// Example demonstrating how to capture the output of a binary
+func ExampleCommand() {
+	ctx := context.Background()
+
+	opts := bolter.RunOptions{
+		Env: append(os.Environ(), "NO_COLOR=1"),
+		Dir: "/tmp",
+	}
+
+	cmd, err := bolter.Command(ctx, "myregistry.io/myapp:latest", []string{"version"}, opts)
+	if err != nil {
+		log.Fatalf("Failed to prepare binary: %v", err)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		log.Fatalf("Failed to run binary: %v", err)
+	}
+
+	fmt.Printf("Version: %s", out)
+}
+
 // Example demonstrating authenticated pull
 func ExamplePull_withAuth() {
 	ctx := context.Background()
@@ -94,3 +118,36 @@ func ExampleRun_customCache() {
 		log.Fatalf("Failed to run binary: %v", err)
 	}
 }
+
+// Example demonstrating how to push binaries for multiple platforms
+func ExamplePush() {
+	ctx := context.Background()
+
+	binaries := []bolter.PlatformBinary{
+		{Path: "./dist/myapp-linux-amd64", OS: "linux", Architecture: "amd64"},
+		{Path: "./dist/myapp-darwin-arm64", OS: "darwin", Architecture: "arm64"},
+	}
+
+	opts := bolter.PushOptions{
+		Logger: slog.Default(),
+	}
+
+	index, err := bolter.Push(ctx, "myregistry.io/myapp:v1.0.0", binaries, opts)
+	if err != nil {
+		log.Fatalf("Failed to push binaries: %v", err)
+	}
+
+	fmt.Printf("Index digest: %s\n", index.Digest)
+}
+
+// Example demonstrating how to list cached binaries
+func ExampleListCached() {
+	entries, err := bolter.ListCached("")
+	if err != nil {
+		log.Fatalf("Failed to list cache: %v", err)
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("%s %s %s\n", entry.Reference, entry.Platform(), entry.Digest)
+	}
+}
@@ -0,0 +1,146 @@
+package bolter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultKillTimeout is how long Run waits for a binary to exit after the
+// graceful signal before killing it
+const DefaultKillTimeout = 10 * time.Second
+
+func executeBinary(ctx context.Context, binaryPath string, args []string, opts RunOptions) error {
+	if opts.UseExec {
+		// Replace current process (CLI behavior)
+		binary, err := exec.LookPath(binaryPath)
+		if err != nil {
+			return err
+		}
+		argv0 := binary
+		if opts.Argv0 != "" {
+			argv0 = opts.Argv0
+		}
+		env := opts.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		if opts.Dir != "" {
+			wd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			if err := os.Chdir(opts.Dir); err != nil {
+				return err
+			}
+			// Only reached if Exec fails
+			defer os.Chdir(wd)
+		}
+		return syscall.Exec(binary, append([]string{argv0}, args...), env)
+	}
+
+	forwardSignals := opts.ForwardSignals
+	if forwardSignals == nil {
+		forwardSignals = defaultForwardSignals
+	}
+
+	// Run as subprocess (library behavior)
+	cmd, err := newCommand(ctx, binaryPath, args, opts)
+	if err != nil {
+		return err
+	}
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	if len(forwardSignals) > 0 {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, forwardSignals...)
+		defer signal.Stop(signals)
+
+		done := make(chan struct{})
+		defer close(done)
+
+		go func() {
+			for {
+				select {
+				case sig := <-signals:
+					// The terminal already sent it to the binary, a second
+					// interrupt often means "force quit"
+					if signalledByTerminal(sig) {
+						continue
+					}
+					cmd.Process.Signal(sig)
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	err = cmd.Wait()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return newExitError(exitErr.ProcessState)
+	}
+	return err
+}
+
+// newCommand returns an unstarted command that runs the binary as
+// configured by opts and is stopped gracefully when ctx is cancelled
+func newCommand(ctx context.Context, binaryPath string, args []string, opts RunOptions) (*exec.Cmd, error) {
+	binary, err := exec.LookPath(binaryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	gracefulSignal := opts.GracefulSignal
+	if gracefulSignal == nil {
+		gracefulSignal = defaultGracefulSignal
+	}
+	killTimeout := opts.KillTimeout
+	if killTimeout <= 0 {
+		killTimeout = DefaultKillTimeout
+	}
+
+	cmd := exec.CommandContext(ctx, binary, args...)
+	if opts.Argv0 != "" {
+		cmd.Args[0] = opts.Argv0
+	}
+	cmd.Dir = opts.Dir
+	cmd.Env = opts.Env
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = opts.Stdout
+	cmd.Stderr = opts.Stderr
+	// On cancellation, ask the binary to exit before it is killed
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(gracefulSignal)
+	}
+	cmd.WaitDelay = killTimeout
+
+	return cmd, nil
+}
+
+// newExitError describes how the binary exited. A binary killed by a signal
+// gets the exit code a shell would report, 128 plus the signal number.
+func newExitError(state *os.ProcessState) *ExitError {
+	if sig, number, ok := exitSignal(state); ok {
+		return &ExitError{Code: 128 + number, Signal: sig}
+	}
+	return &ExitError{Code: state.ExitCode()}
+}
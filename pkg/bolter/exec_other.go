@@ -0,0 +1,19 @@
+//go:build !unix
+
+package bolter
+
+import "os"
+
+// Processes cannot be sent signals other than os.Kill on this platform
+
+var defaultGracefulSignal = os.Kill
+
+var defaultForwardSignals []os.Signal
+
+func exitSignal(state *os.ProcessState) (os.Signal, int, bool) {
+	return nil, 0, false
+}
+
+func signalledByTerminal(sig os.Signal) bool {
+	return false
+}
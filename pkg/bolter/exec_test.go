@@ -0,0 +1,191 @@
+//go:build unix
+
+package bolter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func writeScript(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExecuteBinary(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		opts   RunOptions
+		// cancel, if set, cancels the context after the delay
+		cancel time.Duration
+		// signal, if set, is sent to this process after a delay
+		signal   os.Signal
+		wantCode int
+		wantSig  os.Signal
+	}{
+		{
+			name:   "success",
+			script: "exit 0",
+		},
+		{
+			name:     "exit code",
+			script:   "exit 3",
+			wantCode: 3,
+		},
+		{
+			name:     "graceful cancel",
+			script:   "trap 'exit 7' TERM; while :; do sleep 0.05; done",
+			cancel:   200 * time.Millisecond,
+			wantCode: 7,
+		},
+		{
+			name:     "kill after timeout",
+			script:   "trap '' TERM; while :; do sleep 0.05; done",
+			opts:     RunOptions{KillTimeout: 200 * time.Millisecond},
+			cancel:   200 * time.Millisecond,
+			wantCode: 128 + int(syscall.SIGKILL),
+			wantSig:  syscall.SIGKILL,
+		},
+		{
+			name:     "custom graceful signal",
+			script:   "trap 'exit 8' USR2; while :; do sleep 0.05; done",
+			opts:     RunOptions{GracefulSignal: syscall.SIGUSR2},
+			cancel:   200 * time.Millisecond,
+			wantCode: 8,
+		},
+		{
+			name:     "forwarded signal",
+			script:   "trap 'exit 9' USR1; while :; do sleep 0.05; done",
+			opts:     RunOptions{ForwardSignals: []os.Signal{syscall.SIGUSR1}},
+			signal:   syscall.SIGUSR1,
+			wantCode: 9,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tt.cancel > 0 {
+				time.AfterFunc(tt.cancel, cancel)
+			}
+			if tt.signal != nil {
+				time.AfterFunc(200*time.Millisecond, func() {
+					syscall.Kill(os.Getpid(), tt.signal.(syscall.Signal))
+				})
+			}
+
+			err := executeBinary(ctx, writeScript(t, tt.script), nil, tt.opts)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var exitErr *ExitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("expected ExitError, got %v", err)
+			}
+			if exitErr.Code != tt.wantCode || exitErr.Signal != tt.wantSig {
+				t.Errorf("got code %d signal %v, want code %d signal %v", exitErr.Code, exitErr.Signal, tt.wantCode, tt.wantSig)
+			}
+		})
+	}
+}
+
+func TestExecuteBinaryOptions(t *testing.T) {
+	dir := t.TempDir()
+	script := writeScript(t, `read line; echo "$1 $FOO $(pwd) $line"; echo oops >&2`)
+
+	var stdout, stderr strings.Builder
+	opts := RunOptions{
+		Env:    []string{"FOO=bar"},
+		Dir:    dir,
+		Stdin:  strings.NewReader("hello\n"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	if err := executeBinary(context.Background(), script, []string{"arg"}, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// The working directory may be reported with symlinks resolved
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "arg bar " + realDir + " hello\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+	if stderr.String() != "oops\n" {
+		t.Errorf("stderr = %q, want %q", stderr.String(), "oops\n")
+	}
+}
+
+func TestNewCommandDefaults(t *testing.T) {
+	script := writeScript(t, "exit 0")
+
+	cmd, err := newCommand(context.Background(), script, []string{"a", "b"}, RunOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Args[0] != script || len(cmd.Args) != 3 {
+		t.Errorf("args = %v", cmd.Args)
+	}
+	if cmd.Env != nil || cmd.Dir != "" {
+		t.Errorf("env = %v, dir = %q, want inherited", cmd.Env, cmd.Dir)
+	}
+	if cmd.Stdin != nil || cmd.Stdout != nil || cmd.Stderr != nil {
+		t.Error("expected unset streams")
+	}
+	if cmd.WaitDelay != DefaultKillTimeout {
+		t.Errorf("wait delay = %v, want %v", cmd.WaitDelay, DefaultKillTimeout)
+	}
+
+	cmd, err = newCommand(context.Background(), script, nil, RunOptions{Argv0: "tool"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Path != script || cmd.Args[0] != "tool" {
+		t.Errorf("path = %q, args = %v", cmd.Path, cmd.Args)
+	}
+}
+
+func TestExecuteBinaryExecFailureKeepsDir(t *testing.T) {
+	// An executable file without a known format cannot be executed
+	path := filepath.Join(t.TempDir(), "garbage")
+	if err := os.WriteFile(path, []byte{0, 1, 2, 3}, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := executeBinary(context.Background(), path, nil, RunOptions{UseExec: true, Dir: t.TempDir()}); err == nil {
+		t.Fatal("expected exec to fail")
+	}
+	if got, _ := os.Getwd(); got != wd {
+		t.Errorf("working directory changed to %s", got)
+	}
+}
+
+func TestSignalledByTerminal(t *testing.T) {
+	// Only signals the terminal sends to its foreground process group count
+	if signalledByTerminal(syscall.SIGTERM) || signalledByTerminal(syscall.SIGHUP) {
+		t.Error("SIGTERM and SIGHUP are not sent by the terminal")
+	}
+}
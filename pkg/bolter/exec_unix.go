@@ -0,0 +1,45 @@
+//go:build unix
+
+package bolter
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var defaultGracefulSignal os.Signal = syscall.SIGTERM
+
+var defaultForwardSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// exitSignal returns the signal that killed a process and its number
+func exitSignal(state *os.ProcessState) (os.Signal, int, bool) {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return nil, 0, false
+	}
+	return status.Signal(), int(status.Signal()), true
+}
+
+// signalledByTerminal reports whether sig is sent by the terminal to its
+// foreground process group, and this process is in it. The binary shares
+// the process group of this process, so it received sig as well.
+func signalledByTerminal(sig os.Signal) bool {
+	if sig != syscall.SIGINT && sig != syscall.SIGQUIT {
+		return false
+	}
+
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	foreground, err := unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+	if err != nil {
+		return false
+	}
+	pgrp, err := unix.Getpgid(0)
+	return err == nil && foreground == pgrp
+}
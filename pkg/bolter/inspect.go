@@ -0,0 +1,333 @@
+package bolter
+
+import (
+	"context"
+	"debug/buildinfo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// InspectOptions configures the Inspect operation
+type InspectOptions struct {
+	// Platform in format "os/arch" (e.g., "linux/amd64"). Defaults to current platform.
+	Platform string
+	// Username for registry authentication
+	Username string
+	// Password for registry authentication
+	Password string
+	// Insecure allows insecure registry connections
+	Insecure bool
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+	// Prerelease allows version ranges to resolve to pre-release tags
+	Prerelease bool
+	// Cached inspects the cached copy of ref without contacting the
+	// registry. Version ranges cannot be inspected from the cache.
+	Cached bool
+	// CacheDir overrides the default cache directory (~/.cache/bolter)
+	CacheDir string
+	// Progress is called with the download progress of the binary.
+	// It is not called when the binary is served from the cache.
+	Progress func(Event)
+}
+
+// Inspection describes the artifact of a reference and its binary for one
+// platform
+type Inspection struct {
+	// Reference that was inspected, with version ranges resolved to a
+	// concrete tag
+	Reference string `json:"reference"`
+	// Tag the reference resolved to. Empty for digest references.
+	Tag string `json:"tag,omitempty"`
+	// Digest of the index or manifest the reference points to
+	Digest string `json:"digest"`
+	// MediaType of the index or manifest the reference points to
+	MediaType string `json:"media_type"`
+	// Index is the raw index, if the reference points to one
+	Index json.RawMessage `json:"index,omitempty"`
+	// Platform of the inspected manifest in format "os/arch" or
+	// "os/arch/variant"
+	Platform string `json:"platform"`
+	// ManifestDigest is the digest of the platform manifest
+	ManifestDigest string `json:"manifest_digest"`
+	// Manifest is the raw platform manifest
+	Manifest json.RawMessage `json:"manifest"`
+	// ArtifactType of the manifest, if any
+	ArtifactType string `json:"artifact_type,omitempty"`
+	// Annotations of the manifest
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Created is the time from the org.opencontainers.image.created
+	// annotation, if present
+	Created time.Time `json:"created,omitzero"`
+	// Config is the config descriptor of the manifest
+	Config ocispec.Descriptor `json:"config"`
+	// ConfigData is the config blob, if it is JSON and available
+	ConfigData json.RawMessage `json:"config_data,omitempty"`
+	// Layer is the descriptor of the binary
+	Layer ocispec.Descriptor `json:"layer"`
+	// Path to the cached binary. Empty if the binary is not cached.
+	Path string `json:"path,omitempty"`
+	// BuildInfo is the build information embedded in Go binaries. Nil for
+	// other binaries.
+	BuildInfo *GoBuildInfo `json:"build_info,omitempty"`
+}
+
+// GoBuildInfo is the build information embedded in a Go binary
+type GoBuildInfo struct {
+	// GoVersion is the version of the Go toolchain that built the binary
+	GoVersion string `json:"go_version"`
+	// Path of the main package
+	Path string `json:"path"`
+	// Main is the module containing the main package
+	Main GoModule `json:"main"`
+	// Revision is the VCS revision the binary was built from, if recorded
+	Revision string `json:"revision,omitempty"`
+	// RevisionTime is the commit time of Revision, if recorded
+	RevisionTime string `json:"revision_time,omitempty"`
+	// Modified reports whether the source tree had local changes
+	Modified bool `json:"modified,omitempty"`
+	// Deps are the module dependencies of the binary
+	Deps []GoModule `json:"deps,omitempty"`
+	// Settings are the build settings, such as -ldflags and GOARCH
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// GoModule is a module a Go binary was built from
+type GoModule struct {
+	// Path of the module
+	Path string `json:"path"`
+	// Version of the module
+	Version string `json:"version,omitempty"`
+	// Sum is the checksum of the module
+	Sum string `json:"sum,omitempty"`
+	// Replace is the module that replaced this one, if any
+	Replace *GoModule `json:"replace,omitempty"`
+}
+
+// errNotCached is returned by cacheFetcher for content missing from the cache
+var errNotCached = errors.New("not cached")
+
+// cacheFetcher serves content from the cache only. Everything fetchContent
+// does not find in the cache fails with errNotCached.
+type cacheFetcher struct{}
+
+func (cacheFetcher) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("%s is %w", desc.Digest, errNotCached)
+}
+
+// Inspect returns the index, manifest, annotations and config of ref, and
+// the build information of the binary for the requested platform if it is a
+// Go binary. Unless opts.Cached is set, the binary is downloaded into the
+// cache to read its build information.
+func Inspect(ctx context.Context, ref string, opts InspectOptions) (_ *Inspection, err error) {
+	defer func() { err = classifyError(err) }()
+
+	target := parsePlatform(opts.Platform)
+	log := logger(opts.Logger)
+
+	cacheDir, err := getCacheDir(opts.CacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory: %w", err)
+	}
+
+	base, rng, isRange := splitVersionRange(ref)
+	if isRange {
+		if opts.Cached {
+			return nil, fmt.Errorf("version range %s cannot be inspected from the cache", rng)
+		}
+		ref = base
+	}
+
+	repo, err := createRepository(ref, opts.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	var src content.Fetcher = cacheFetcher{}
+	var descriptor ocispec.Descriptor
+
+	if opts.Cached {
+		if isDigestReference(repo.Reference) {
+			dgst, _ := repo.Reference.Digest()
+			descriptor = ocispec.Descriptor{Digest: dgst}
+		} else {
+			cached, err := loadCacheRef(cacheDir, repo.Reference)
+			if err != nil || cached.Digest == "" {
+				return nil, fmt.Errorf("%s is not cached: %w", repo.Reference, ErrNotFound)
+			}
+			descriptor = cached.descriptor()
+		}
+	} else {
+		// Setup authentication
+		if err := setupAuth(repo, opts.Username, opts.Password, log); err != nil {
+			return nil, err
+		}
+
+		if isRange {
+			tag, err := resolveVersionRange(ctx, repo, rng, opts.Prerelease)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve version range: %w", err)
+			}
+			repo.Reference.Reference = tag
+			log.Info("Resolved version range", "range", rng, "tag", tag)
+		}
+
+		descriptor, err = repo.Resolve(ctx, repo.Reference.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve reference: %w", err)
+		}
+		src = repo
+	}
+
+	inspection, err := inspectDescriptor(ctx, src, cacheDir, descriptor, target)
+	if err != nil {
+		if errors.Is(err, errNotCached) {
+			return nil, fmt.Errorf("%s is not cached for %s: %w", repo.Reference, platformString(target), ErrNotFound)
+		}
+		return nil, err
+	}
+
+	inspection.Reference = repo.Reference.String()
+	if !isDigestReference(repo.Reference) {
+		inspection.Tag = repo.Reference.Reference
+	}
+
+	blobPath := getBlobPath(cacheDir, inspection.Layer.Digest)
+	if opts.Cached {
+		if verifyFile(blobPath, inspection.Layer.Digest) != nil {
+			return inspection, nil
+		}
+	} else {
+		progress := newProgressReporter(opts.Progress, inspection.Platform, inspection.Layer.Digest, inspection.Layer.Size)
+		blobPath, _, err = fetchBlobToCache(ctx, src, cacheDir, inspection.Layer, false, progress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull binary: %w", err)
+		}
+
+		// Record the binary like Pull does, so that it is kept in the cache
+		// and can be inspected with opts.Cached
+		if !isDigestReference(repo.Reference) {
+			if err := saveCacheRef(cacheDir, repo.Reference, descriptor, DefaultResolveTTL); err != nil {
+				log.Warn("Failed to save cache ref", "ref", repo.Reference, "error", err)
+			}
+		}
+		manifestDesc := ocispec.Descriptor{Digest: digest.Digest(inspection.ManifestDigest)}
+		if err := saveCacheMetadata(cacheDir, repo.Reference, parsePlatform(inspection.Platform), manifestDesc, inspection.Layer); err != nil {
+			log.Warn("Failed to save cache metadata", "ref", repo.Reference, "error", err)
+		}
+	}
+	inspection.Path = blobPath
+
+	// Binaries that were not built by Go have no build information
+	if info, err := buildinfo.ReadFile(blobPath); err == nil {
+		inspection.BuildInfo = newGoBuildInfo(info)
+	}
+
+	return inspection, nil
+}
+
+// inspectDescriptor fetches the index or manifest desc refers to, the
+// manifest for target and its config into an Inspection
+func inspectDescriptor(ctx context.Context, src content.Fetcher, cacheDir string, desc ocispec.Descriptor, target ocispec.Platform) (*Inspection, error) {
+	manifestDesc, err := selectManifest(ctx, src, cacheDir, desc, target)
+	if err != nil {
+		return nil, err
+	}
+
+	inspection := &Inspection{
+		Digest:         desc.Digest.String(),
+		MediaType:      ocispec.MediaTypeImageManifest,
+		Platform:       platformString(manifestPlatform(manifestDesc, target)),
+		ManifestDigest: manifestDesc.Digest.String(),
+	}
+
+	if manifestDesc.Digest != desc.Digest {
+		inspection.MediaType = ocispec.MediaTypeImageIndex
+		if inspection.Index, err = fetchContent(ctx, src, cacheDir, desc); err != nil {
+			return nil, err
+		}
+	}
+
+	if inspection.Manifest, err = fetchContent(ctx, src, cacheDir, manifestDesc); err != nil {
+		return nil, err
+	}
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(inspection.Manifest, &manifest); err != nil {
+		return nil, err
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, fmt.Errorf("manifest has no layers")
+	}
+
+	inspection.ArtifactType = manifest.ArtifactType
+	inspection.Annotations = manifest.Annotations
+	inspection.Config = manifest.Config
+	inspection.Layer = manifest.Layers[0]
+
+	if created, ok := manifest.Annotations[ocispec.AnnotationCreated]; ok {
+		inspection.Created, _ = time.Parse(time.RFC3339, created)
+	}
+
+	// The config is not cached: no cache entry would reference it
+	config, err := fetchContent(ctx, src, "", manifest.Config)
+	if err != nil && !errors.Is(err, errNotCached) {
+		return nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	if err == nil && json.Valid(config) {
+		inspection.ConfigData = config
+	}
+
+	return inspection, nil
+}
+
+// newGoBuildInfo converts the build information of a Go binary
+func newGoBuildInfo(info *debug.BuildInfo) *GoBuildInfo {
+	result := &GoBuildInfo{
+		GoVersion: info.GoVersion,
+		Path:      info.Path,
+		Main:      *newGoModule(&info.Main),
+	}
+
+	for _, dep := range info.Deps {
+		result.Deps = append(result.Deps, *newGoModule(dep))
+	}
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			result.Revision = setting.Value
+		case "vcs.time":
+			result.RevisionTime = setting.Value
+		case "vcs.modified":
+			result.Modified = setting.Value == "true"
+		}
+		if result.Settings == nil {
+			result.Settings = make(map[string]string)
+		}
+		result.Settings[setting.Key] = setting.Value
+	}
+
+	return result
+}
+
+func newGoModule(m *debug.Module) *GoModule {
+	if m == nil {
+		return nil
+	}
+	return &GoModule{
+		Path:    m.Path,
+		Version: m.Version,
+		Sum:     m.Sum,
+		Replace: newGoModule(m.Replace),
+	}
+}
@@ -0,0 +1,65 @@
+package bolter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestInspect(t *testing.T) {
+	ctx := context.Background()
+	_, host := newTestRegistry(t)
+	cacheDir := t.TempDir()
+
+	// The test binary is a Go binary with build information
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := host + "/org/tool:v1"
+	pushed, err := Push(ctx, ref, []PlatformBinary{{Path: self}}, PushOptions{Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Inspect(ctx, ref, InspectOptions{Cached: true, CacheDir: cacheDir})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound before caching, got %v", err)
+	}
+
+	inspection, err := Inspect(ctx, ref, InspectOptions{Insecure: true, CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inspection.Digest != pushed.Digest.String() || inspection.MediaType != ocispec.MediaTypeImageIndex || inspection.Tag != "v1" {
+		t.Errorf("inspection = %+v", inspection)
+	}
+	if inspection.Index == nil || inspection.Manifest == nil || inspection.ConfigData == nil {
+		t.Errorf("missing raw index, manifest or config")
+	}
+	if inspection.Annotations[ocispec.AnnotationTitle] == "" && inspection.Layer.Annotations[ocispec.AnnotationTitle] == "" {
+		t.Errorf("no title annotation in %s", inspection.Manifest)
+	}
+	if inspection.BuildInfo == nil || inspection.BuildInfo.GoVersion != runtime.Version() {
+		t.Fatalf("build info = %+v, want Go version %s", inspection.BuildInfo, runtime.Version())
+	}
+
+	// Inspect records the binary in the cache like Pull does
+	cached, err := Inspect(ctx, ref, InspectOptions{Cached: true, CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.ManifestDigest != inspection.ManifestDigest || cached.BuildInfo == nil || cached.Path != filepath.Join(cacheDir, "blobs", "sha256", inspection.Layer.Digest.Encoded()) {
+		t.Errorf("cached inspection = %+v", cached)
+	}
+
+	if _, err := json.Marshal(cached); err != nil {
+		t.Errorf("marshal inspection: %v", err)
+	}
+}
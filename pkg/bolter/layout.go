@@ -0,0 +1,485 @@
+package bolter
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/registry"
+)
+
+// Artifacts can be kept in an OCI image layout on disk instead of a
+// registry, either as a directory or as a tarball of one. Pull and Run
+// accept references to them:
+//
+//	oci-layout:<dir>[:<tag>|@<digest>]
+//	oci-archive:<file>[:<tag>|@<digest>]
+//
+// The tag may be omitted if the layout contains a single tag.
+
+const (
+	layoutPrefix  = "oci-layout:"
+	archivePrefix = "oci-archive:"
+)
+
+// SaveOptions configures the Save operation
+type SaveOptions struct {
+	// Output is the path of the tarball to write
+	Output string
+	// Layout is the directory of the OCI image layout to write. An existing
+	// layout is added to. Exactly one of Output and Layout must be set.
+	Layout string
+	// Platforms in format "os/arch" or "os/arch/variant" to keep. The saved
+	// index only lists these platforms, matched exactly without substituting
+	// other variants. Empty keeps all platforms.
+	Platforms []string
+	// Username for registry authentication
+	Username string
+	// Password for registry authentication
+	Password string
+	// Insecure allows insecure registry connections
+	Insecure bool
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+	// Prerelease allows version ranges to resolve to pre-release tags
+	Prerelease bool
+}
+
+// LoadOptions configures the Load operation
+type LoadOptions struct {
+	// Username for registry authentication
+	Username string
+	// Password for registry authentication
+	Password string
+	// Insecure allows insecure registry connections
+	Insecure bool
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+}
+
+// layoutReference refers to an artifact in an OCI image layout on disk
+type layoutReference struct {
+	// Path of the layout directory or tarball
+	Path string
+	// Archive is set if Path is a tarball
+	Archive bool
+	// Reference is the tag or digest within the layout. Empty selects the
+	// only tag of the layout.
+	Reference string
+}
+
+func (r layoutReference) String() string {
+	s := layoutPrefix
+	if r.Archive {
+		s = archivePrefix
+	}
+	s += r.Path
+	switch {
+	case r.Reference == "":
+	case digest.Digest(r.Reference).Validate() == nil:
+		s += "@" + r.Reference
+	default:
+		s += ":" + r.Reference
+	}
+	return s
+}
+
+// parseLayoutReference parses an oci-layout: or oci-archive: reference. It
+// reports false for registry references.
+func parseLayoutReference(ref string) (layoutReference, bool) {
+	var lr layoutReference
+	switch {
+	case strings.HasPrefix(ref, layoutPrefix):
+		lr.Path, lr.Reference = splitLayoutPath(strings.TrimPrefix(ref, layoutPrefix))
+	case strings.HasPrefix(ref, archivePrefix):
+		lr.Path, lr.Reference = splitLayoutPath(strings.TrimPrefix(ref, archivePrefix))
+		lr.Archive = true
+	default:
+		return lr, false
+	}
+	return lr, true
+}
+
+// splitLayoutPath splits "path:tag" or "path@digest" into the path and the
+// reference within the layout. Colons that are part of the path, such as
+// Windows drive letters, are left alone.
+func splitLayoutPath(s string) (string, string) {
+	if at := strings.LastIndex(s, "@"); at >= 0 && digest.Digest(s[at+1:]).Validate() == nil {
+		return s[:at], s[at+1:]
+	}
+
+	colon := strings.LastIndex(s, ":")
+	if colon <= 0 || strings.ContainsAny(s[colon+1:], `/\`) || (colon == 1 && filepath.VolumeName(s[:2]) != "") {
+		return s, ""
+	}
+	return s[:colon], s[colon+1:]
+}
+
+// layoutStore is a read-only OCI image layout
+type layoutStore interface {
+	content.ReadOnlyStorage
+	content.Resolver
+	registry.TagLister
+}
+
+// openLayout opens the layout directory or tarball of lr
+func openLayout(ctx context.Context, lr layoutReference) (layoutStore, error) {
+	if lr.Archive {
+		return oci.NewFromTar(ctx, lr.Path)
+	}
+	return oci.NewFromFS(ctx, os.DirFS(lr.Path))
+}
+
+// resolveLayout resolves the reference of lr within store, defaulting to
+// the only tag of the layout. It returns the descriptor and the reference
+// it was resolved from.
+func resolveLayout(ctx context.Context, store layoutStore, lr layoutReference) (ocispec.Descriptor, string, error) {
+	reference := lr.Reference
+	if reference == "" {
+		var tags []string
+		err := store.Tags(ctx, "", func(page []string) error {
+			tags = append(tags, page...)
+			return nil
+		})
+		if err != nil {
+			return ocispec.Descriptor{}, "", fmt.Errorf("failed to list tags: %w", err)
+		}
+		if len(tags) != 1 {
+			return ocispec.Descriptor{}, "", fmt.Errorf("%s has %d tags, specify one of: %s", lr.Path, len(tags), strings.Join(tags, ", "))
+		}
+		reference = tags[0]
+	}
+
+	desc, err := store.Resolve(ctx, reference)
+	if err != nil {
+		return ocispec.Descriptor{}, "", err
+	}
+
+	return desc, reference, nil
+}
+
+// Save copies the index tagged as ref, every platform manifest and every
+// blob from the registry into an OCI image layout, written as a tarball to
+// opts.Output or as a directory to opts.Layout. The artifact is tagged in
+// the layout like in the registry, so that it can be pulled or run as
+// "oci-archive:<file>:<tag>" or "oci-layout:<dir>:<tag>", and pushed to
+// another registry with Load. It returns the descriptor of the saved index.
+func Save(ctx context.Context, ref string, opts SaveOptions) (_ ocispec.Descriptor, err error) {
+	defer func() { err = classifyError(err) }()
+
+	if (opts.Output == "") == (opts.Layout == "") {
+		return ocispec.Descriptor{}, fmt.Errorf("exactly one of output and layout must be set")
+	}
+
+	log := logger(opts.Logger)
+
+	base, rng, isRange := splitVersionRange(ref)
+	if isRange {
+		ref = base
+	}
+
+	repo, err := createRepository(ref, opts.Insecure)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	// Setup authentication
+	if err := setupAuth(repo, opts.Username, opts.Password, log); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	if isRange {
+		tag, err := resolveVersionRange(ctx, repo, rng, opts.Prerelease)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to resolve version range: %w", err)
+		}
+		repo.Reference.Reference = tag
+	}
+
+	desc, err := repo.Resolve(ctx, repo.Reference.Reference)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to resolve reference: %w", err)
+	}
+
+	layoutDir := opts.Layout
+	if opts.Output != "" {
+		outputDir := filepath.Dir(opts.Output)
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		layoutDir, err = os.MkdirTemp(outputDir, "."+filepath.Base(opts.Output)+".*.tmp")
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		defer os.RemoveAll(layoutDir)
+	}
+
+	store, err := oci.NewWithContext(ctx, layoutDir)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to open layout: %w", err)
+	}
+
+	log.Info("Saving", "ref", repo.Reference, "digest", desc.Digest)
+
+	if len(opts.Platforms) > 0 {
+		desc, err = copyFilteredIndex(ctx, repo, store, desc, opts.Platforms, oras.DefaultCopyGraphOptions, log)
+	} else {
+		err = oras.CopyGraph(ctx, repo, store, desc, oras.DefaultCopyGraphOptions)
+	}
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to save: %w", err)
+	}
+
+	// Digest references are kept as untagged entries of the layout, by the
+	// digest of the saved index, which differs from the reference if
+	// platforms were filtered
+	tag := desc.Digest.String()
+	if !isDigestReference(repo.Reference) {
+		tag = repo.Reference.Reference
+	}
+	if err := store.Tag(ctx, desc, tag); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to tag layout: %w", err)
+	}
+
+	if opts.Output != "" {
+		if err := writeArchive(layoutDir, opts.Output); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+
+	log.Info("Saved", "ref", repo.Reference, "digest", desc.Digest, "output", opts.Output, "layout", opts.Layout)
+
+	return desc, nil
+}
+
+// writeArchive writes the layout in dir as a tarball to output, replacing
+// output atomically
+func writeArchive(dir, output string) error {
+	f, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	tw := tar.NewWriter(f)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, output)
+}
+
+// Load pushes an artifact from an OCI image layout written by Save to the
+// registry as ref. src is the path of the layout directory or tarball,
+// optionally followed by ":<tag>" or "@<digest>" to select an artifact if
+// the layout contains several. If ref has no tag, the tag of the artifact
+// in the layout is used. It returns the descriptor of the pushed index.
+func Load(ctx context.Context, src, ref string, opts LoadOptions) (_ ocispec.Descriptor, err error) {
+	defer func() { err = classifyError(err) }()
+
+	log := logger(opts.Logger)
+
+	var lr layoutReference
+	lr.Path, lr.Reference = splitLayoutPath(src)
+	info, err := os.Stat(lr.Path)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	lr.Archive = !info.IsDir()
+
+	store, err := openLayout(ctx, lr)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to open layout: %w", err)
+	}
+
+	desc, srcRef, err := resolveLayout(ctx, store, lr)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to resolve reference: %w", err)
+	}
+
+	repo, err := createRepository(ref, opts.Insecure)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	// Setup authentication
+	if err := setupAuth(repo, opts.Username, opts.Password, log); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	dstRef := repo.Reference.Reference
+	if dstRef == "" {
+		dstRef = srcRef
+	}
+
+	log.Info("Loading", "src", lr, "ref", repo.Reference.Registry+"/"+repo.Reference.Repository, "tag", dstRef, "digest", desc.Digest)
+
+	if _, err := oras.Copy(ctx, store, srcRef, repo, dstRef, oras.DefaultCopyOptions); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to load: %w", err)
+	}
+
+	return desc, nil
+}
+
+// pullLayout implements Pull for layout references. The binary is copied to
+// the blob cache and recorded if opts.UseCache is set. If refresh is set, a
+// cached copy is replaced and the binary is not recorded, like Run does
+// without the cache.
+func pullLayout(ctx context.Context, lr layoutReference, opts PullOptions, refresh bool) (*BinaryInfo, error) {
+	target := parsePlatform(opts.Platform)
+
+	var cacheDir string
+	var err error
+	if opts.UseCache {
+		cacheDir, err = getCacheDir(opts.CacheDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cache directory: %w", err)
+		}
+	} else if opts.Output == "" {
+		return nil, fmt.Errorf("output path required when caching is disabled")
+	}
+
+	store, err := openLayout(ctx, lr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open layout: %w", err)
+	}
+
+	descriptor, reference, err := resolveLayout(ctx, store, lr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reference: %w", err)
+	}
+	lr.Reference = reference
+
+	manifestDesc, err := selectManifest(ctx, store, "", descriptor, target)
+	if err != nil {
+		return nil, err
+	}
+
+	layerDesc, err := resolveLayer(ctx, store, "", manifestDesc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull binary: %w", err)
+	}
+
+	platform := manifestPlatform(manifestDesc, target)
+	progress := newProgressReporter(opts.Progress, platformString(platform), layerDesc.Digest, layerDesc.Size)
+
+	outputPath := opts.Output
+	cached := false
+	if opts.UseCache {
+		blobPath, fromCache, err := fetchBlobToCache(ctx, store, cacheDir, layerDesc, refresh, progress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull binary: %w", err)
+		}
+		cached = fromCache
+
+		if !refresh {
+			if err := saveLayoutCacheMetadata(cacheDir, lr, platform, manifestDesc, layerDesc); err != nil {
+				logger(opts.Logger).Warn("Failed to save cache metadata", "ref", lr, "error", err)
+			}
+		}
+
+		if outputPath == "" {
+			outputPath = blobPath
+		} else {
+			if outputDir := filepath.Dir(outputPath); outputDir != "." && outputDir != "" {
+				if err := os.MkdirAll(outputDir, 0755); err != nil {
+					return nil, err
+				}
+			}
+			if err := copyFile(blobPath, outputPath); err != nil {
+				return nil, fmt.Errorf("failed to copy binary from cache: %w", err)
+			}
+		}
+	} else if err := downloadBlob(ctx, store, layerDesc, outputPath, progress); err != nil {
+		return nil, fmt.Errorf("failed to pull binary: %w", err)
+	}
+
+	var tag string
+	if digest.Digest(reference).Validate() != nil {
+		tag = reference
+	}
+
+	return &BinaryInfo{
+		Path:         outputPath,
+		Reference:    lr.String(),
+		Tag:          tag,
+		Digest:       manifestDesc.Digest.String(),
+		Size:         layerDesc.Size,
+		OS:           platform.OS,
+		Architecture: platform.Architecture,
+		Variant:      platform.Variant,
+		Cached:       cached,
+	}, nil
+}
+
+// fetchLayoutBinary implements fetchRunBinary for layout references
+func fetchLayoutBinary(ctx context.Context, lr layoutReference, opts RunOptions, msg string) (string, error) {
+	info, err := pullLayout(ctx, lr, PullOptions{
+		Platform: opts.Platform,
+		UseCache: true,
+		CacheDir: opts.CacheDir,
+		Progress: opts.Progress,
+	}, opts.NoCache)
+	if err != nil {
+		return "", err
+	}
+
+	logger(opts.Logger).Info(msg, "ref", info.Reference, "digest", info.Digest, "platform", info.platform(), "path", info.Path, "cached", info.Cached)
+
+	return info.Path, nil
+}
@@ -0,0 +1,367 @@
+package bolter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestParseLayoutReference(t *testing.T) {
+	dgst := digest.FromString("index").String()
+
+	tests := []struct {
+		ref  string
+		want layoutReference
+		ok   bool
+	}{
+		{ref: "ghcr.io/org/tool:v1"},
+		{ref: "oci-layout:./dir:v1", want: layoutReference{Path: "./dir", Reference: "v1"}, ok: true},
+		{ref: "oci-layout:./dir", want: layoutReference{Path: "./dir"}, ok: true},
+		{ref: "oci-layout:/a:b/dir", want: layoutReference{Path: "/a:b/dir"}, ok: true},
+		{ref: "oci-archive:app.tar:v1", want: layoutReference{Path: "app.tar", Archive: true, Reference: "v1"}, ok: true},
+		{ref: "oci-archive:app.tar@" + dgst, want: layoutReference{Path: "app.tar", Archive: true, Reference: dgst}, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, ok := parseLayoutReference(tt.ref)
+			if ok != tt.ok || got != tt.want {
+				t.Fatalf("parseLayoutReference(%q) = %+v, %v, want %+v, %v", tt.ref, got, ok, tt.want, tt.ok)
+			}
+			if ok && got.String() != tt.ref {
+				t.Errorf("String() = %q, want %q", got.String(), tt.ref)
+			}
+		})
+	}
+}
+
+// writeTestLayout writes a layout with an index tagged as tag, holding the
+// given binary for linux/amd64
+func writeTestLayout(t *testing.T, dir, tag string, binary []byte) ocispec.Descriptor {
+	t.Helper()
+	return writeTestLayoutPlatforms(t, dir, tag, map[string][]byte{"linux/amd64": binary})
+}
+
+// writeTestLayoutPlatforms writes a layout with an index tagged as tag,
+// holding a binary for each platform in format "os/arch[/variant]"
+func writeTestLayoutPlatforms(t *testing.T, dir, tag string, binaries map[string][]byte) ocispec.Descriptor {
+	t.Helper()
+	ctx := context.Background()
+
+	store, err := oci.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configDesc, err := oras.PushBytes(ctx, store, ocispec.MediaTypeImageConfig, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+	}
+
+	for _, platform := range slices.Sorted(maps.Keys(binaries)) {
+		layerDesc, err := oras.PushBytes(ctx, store, "application/vnd.bolter.elf.v1", binaries[platform])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		manifest := ocispec.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    configDesc,
+			Layers:    []ocispec.Descriptor{layerDesc},
+		}
+		manifestDesc, err := oras.PushBytes(ctx, store, ocispec.MediaTypeImageManifest, mustMarshal(t, manifest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := parsePlatform(platform)
+		manifestDesc.Platform = &p
+		index.Manifests = append(index.Manifests, manifestDesc)
+	}
+
+	indexDesc, err := oras.PushBytes(ctx, store, ocispec.MediaTypeImageIndex, mustMarshal(t, index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Tag(ctx, indexDesc, tag); err != nil {
+		t.Fatal(err)
+	}
+
+	return indexDesc
+}
+
+func TestPullLayout(t *testing.T) {
+	binary := []byte("\x7fELF not really")
+	dir := t.TempDir()
+	layoutDir := filepath.Join(dir, "layout")
+	writeTestLayout(t, layoutDir, "v1", binary)
+
+	archive := filepath.Join(dir, "app.tar")
+	if err := writeArchive(layoutDir, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ref  string
+		opts PullOptions
+	}{
+		{
+			name: "layout",
+			ref:  "oci-layout:" + layoutDir + ":v1",
+			opts: PullOptions{Output: filepath.Join(dir, "out", "layout")},
+		},
+		{
+			name: "archive with only tag",
+			ref:  "oci-archive:" + archive,
+			opts: PullOptions{Output: filepath.Join(dir, "out", "archive"), UseCache: true, CacheDir: filepath.Join(dir, "cache")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Platform = "linux/amd64"
+			info, err := Pull(context.Background(), tt.ref, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Tag != "v1" || info.OS != "linux" || info.Architecture != "amd64" {
+				t.Errorf("info = %+v", info)
+			}
+
+			data, err := os.ReadFile(tt.opts.Output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, binary) {
+				t.Errorf("pulled %q, want %q", data, binary)
+			}
+		})
+	}
+}
+
+func TestPullLayoutPlatformNotFound(t *testing.T) {
+	layoutDir := t.TempDir()
+	writeTestLayout(t, layoutDir, "v1", []byte("binary"))
+
+	_, err := Pull(context.Background(), "oci-layout:"+layoutDir+":v1", PullOptions{
+		Output:   filepath.Join(t.TempDir(), "out"),
+		Platform: "darwin/arm64",
+	})
+	if !errors.Is(err, ErrPlatformNotFound) {
+		t.Fatalf("expected ErrPlatformNotFound, got %v", err)
+	}
+}
+
+func TestWriteArchiveIndex(t *testing.T) {
+	dir := t.TempDir()
+	layoutDir := filepath.Join(dir, "layout")
+	indexDesc := writeTestLayout(t, layoutDir, "v1", []byte("binary"))
+
+	archive := filepath.Join(dir, "app.tar")
+	if err := writeArchive(layoutDir, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := oci.NewFromTar(context.Background(), archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := store.Resolve(context.Background(), "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != indexDesc.Digest {
+		t.Errorf("archive resolves v1 to %s, want %s", desc.Digest, indexDesc.Digest)
+	}
+
+	var index ocispec.Index
+	data, err := os.ReadFile(filepath.Join(layoutDir, "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	var tagged []digest.Digest
+	for _, m := range index.Manifests {
+		if m.Annotations[ocispec.AnnotationRefName] != "" {
+			tagged = append(tagged, m.Digest)
+		}
+	}
+	if len(tagged) != 1 || tagged[0] != indexDesc.Digest {
+		t.Errorf("index.json = %s", data)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	reg, host := newTestRegistry(t)
+	binary := []byte("\x7fELF not really")
+	dir := t.TempDir()
+
+	indexDesc := writeTestLayout(t, filepath.Join(dir, "layout"), "v1", binary)
+
+	// Without a tag on the destination, the tag from the layout is used
+	loaded, err := Load(ctx, filepath.Join(dir, "layout"), host+"/org/tool", LoadOptions{Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Digest != indexDesc.Digest || reg.tag("org/tool", "v1") != indexDesc.Digest.String() {
+		t.Fatalf("loaded %s, registry has %s, want %s", loaded.Digest, reg.tag("org/tool", "v1"), indexDesc.Digest)
+	}
+
+	archive := filepath.Join(dir, "saved", "tool.tar")
+	saved, err := Save(ctx, host+"/org/tool:v1", SaveOptions{Output: archive, Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Digest != indexDesc.Digest {
+		t.Errorf("saved %s, want %s", saved.Digest, indexDesc.Digest)
+	}
+
+	output := filepath.Join(dir, "tool")
+	if _, err := Pull(ctx, "oci-archive:"+archive+":v1", PullOptions{Output: output, Platform: "linux/amd64"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(output); !bytes.Equal(data, binary) {
+		t.Errorf("pulled %q, want %q", data, binary)
+	}
+
+	filtered := filepath.Join(dir, "filtered")
+	if _, err := Save(ctx, host+"/org/tool:v1", SaveOptions{Layout: filtered, Platforms: []string{"linux/amd64"}, Insecure: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Pull(ctx, "oci-layout:"+filtered, PullOptions{Output: output, Platform: "linux/amd64"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Save(ctx, host+"/org/tool:v1", SaveOptions{Layout: filtered, Platforms: []string{"darwin/arm64"}, Insecure: true})
+	if !errors.Is(err, ErrPlatformNotFound) {
+		t.Errorf("expected ErrPlatformNotFound, got %v", err)
+	}
+}
+
+// indexPlatforms returns the platforms listed in the index desc
+func indexPlatforms(t *testing.T, src content.Fetcher, desc ocispec.Descriptor) []string {
+	t.Helper()
+
+	data, err := content.FetchAll(context.Background(), src, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	var platforms []string
+	for _, m := range index.Manifests {
+		platforms = append(platforms, platformString(*m.Platform))
+	}
+	return platforms
+}
+
+func TestSavePlatformsExact(t *testing.T) {
+	ctx := context.Background()
+	_, host := newTestRegistry(t)
+	dir := t.TempDir()
+
+	writeTestLayoutPlatforms(t, filepath.Join(dir, "layout"), "v1", map[string][]byte{
+		"linux/amd64":    []byte("baseline"),
+		"linux/amd64/v3": []byte("v3"),
+		"linux/arm/v6":   []byte("armv6"),
+	})
+	if _, err := Load(ctx, filepath.Join(dir, "layout"), host+"/org/tool", LoadOptions{Insecure: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The baseline build is saved even on hosts that would run amd64/v3
+	saved := filepath.Join(dir, "saved")
+	desc, err := Save(ctx, host+"/org/tool:v1", SaveOptions{Layout: saved, Platforms: []string{"linux/amd64"}, Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := oci.New(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := indexPlatforms(t, store, desc); !slices.Equal(got, []string{"linux/amd64"}) {
+		t.Errorf("saved %v, want [linux/amd64]", got)
+	}
+
+	// arm/v6 could run where arm/v7 was asked for, but is not substituted
+	_, err = Save(ctx, host+"/org/tool:v1", SaveOptions{Layout: filepath.Join(dir, "arm"), Platforms: []string{"linux/arm/v7"}, Insecure: true})
+	if !errors.Is(err, ErrPlatformNotFound) {
+		t.Errorf("expected ErrPlatformNotFound, got %v", err)
+	}
+}
+
+func TestSavePlatformsByDigest(t *testing.T) {
+	ctx := context.Background()
+	_, host := newTestRegistry(t)
+	dir := t.TempDir()
+
+	index := writeTestLayoutPlatforms(t, filepath.Join(dir, "layout"), "v1", map[string][]byte{
+		"linux/amd64":  []byte("amd64"),
+		"darwin/arm64": []byte("arm64"),
+	})
+	if _, err := Load(ctx, filepath.Join(dir, "layout"), host+"/org/tool", LoadOptions{Insecure: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := filepath.Join(dir, "saved")
+	desc, err := Save(ctx, host+"/org/tool@"+index.Digest.String(), SaveOptions{Layout: saved, Platforms: []string{"linux/amd64"}, Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest == index.Digest {
+		t.Fatal("expected a filtered index")
+	}
+
+	// The filtered index is an untagged entry under its own digest
+	data, err := os.ReadFile(filepath.Join(saved, "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var layoutIndex ocispec.Index
+	if err := json.Unmarshal(data, &layoutIndex); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, entry := range layoutIndex.Manifests {
+		if name, ok := entry.Annotations[ocispec.AnnotationRefName]; ok {
+			t.Errorf("%s tagged as %q", entry.Digest, name)
+		}
+		found = found || entry.Digest == desc.Digest
+	}
+	if !found {
+		t.Errorf("filtered index %s not in layout", desc.Digest)
+	}
+
+	output := filepath.Join(dir, "tool")
+	if _, err := Pull(ctx, "oci-layout:"+saved+"@"+desc.Digest.String(), PullOptions{Output: output, Platform: "linux/amd64"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "amd64" {
+		t.Errorf("pulled %q, want amd64", data)
+	}
+}
@@ -0,0 +1,82 @@
+package bolter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ListOptions configures the List operation
+type ListOptions struct {
+	// Username for registry authentication
+	Username string
+	// Password for registry authentication
+	Password string
+	// Insecure allows insecure registry connections
+	Insecure bool
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+	// Prerelease allows version ranges to resolve to pre-release tags
+	Prerelease bool
+}
+
+// Listing describes the platforms available for a reference
+type Listing struct {
+	ResolvedReference
+	// Manifests are the platform manifests of the index, if the reference
+	// points to one
+	Manifests []ocispec.Descriptor
+	// Manifest is the manifest the reference points to, if it is not an
+	// index
+	Manifest *ocispec.Manifest
+}
+
+// List resolves ref like Resolve and returns the platforms of the index it
+// points to, or the manifest if it is a single platform artifact
+func List(ctx context.Context, ref string, opts ListOptions) (_ *Listing, err error) {
+	defer func() { err = classifyError(err) }()
+
+	repo, descriptor, resolved, err := resolveReference(ctx, ref, ResolveOptions{
+		Username:   opts.Username,
+		Password:   opts.Password,
+		Insecure:   opts.Insecure,
+		Logger:     opts.Logger,
+		Prerelease: opts.Prerelease,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger(opts.Logger).Debug("Resolved reference", "ref", resolved.Reference, "digest", resolved.Digest, "media_type", resolved.MediaType)
+
+	listing := &Listing{ResolvedReference: *resolved}
+
+	switch descriptor.MediaType {
+	case ocispec.MediaTypeImageIndex:
+		data, err := fetchContent(ctx, repo, "", descriptor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch index: %w", err)
+		}
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("failed to parse index: %w", err)
+		}
+		listing.Manifests = index.Manifests
+	case ocispec.MediaTypeImageManifest:
+		data, err := fetchContent(ctx, repo, "", descriptor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+		}
+		listing.Manifest = &ocispec.Manifest{}
+		if err := json.Unmarshal(data, listing.Manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported media type %s", descriptor.MediaType)
+	}
+
+	return listing, nil
+}
@@ -0,0 +1,33 @@
+package bolter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	_, host := newTestRegistry(t)
+
+	pushTestBinary(t, host+"/org/tool:v1.2.0", "one")
+	pushTestBinary(t, host+"/org/tool:v1.3.0", "two")
+
+	listing, err := List(ctx, host+"/org/tool@^1.2", ListOptions{Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listing.Tag != "v1.3.0" || listing.Range != "^1.2" || listing.MediaType != ocispec.MediaTypeImageIndex {
+		t.Errorf("listing = %+v", listing)
+	}
+	if len(listing.Manifests) != 1 || listing.Manifests[0].Platform == nil || platformString(*listing.Manifests[0].Platform) != "linux/amd64" {
+		t.Errorf("manifests = %+v", listing.Manifests)
+	}
+
+	_, err = List(ctx, host+"/org/tool:v2", ListOptions{Insecure: true})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
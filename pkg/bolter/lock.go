@@ -0,0 +1,109 @@
+package bolter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPollInterval is how often a blocked caller retries to take a cache lock
+const lockPollInterval = 100 * time.Millisecond
+
+// lockCacheEntry takes an exclusive, cross-process lock for the cache entry
+// at path, waiting until it becomes available or ctx is done.
+// The returned function releases the lock.
+func lockCacheEntry(ctx context.Context, path string) (func(), error) {
+	lockPath := path + ".lock"
+
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(ctx, f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// tryLockCacheEntry takes the lock of lockCacheEntry if it is free. It
+// reports false if the lock is held or cannot be taken.
+func tryLockCacheEntry(path string) (func(), bool) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, false
+	}
+
+	if locked, err := tryLockFile(f); err != nil || !locked {
+		f.Close()
+		return nil, false
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, true
+}
+
+// lockFile takes an exclusive, cross-process lock on f, waiting until it
+// becomes available or ctx is done. The lock is released by unlockFile or
+// by closing f.
+func lockFile(ctx context.Context, f *os.File) error {
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			return err
+		}
+		if locked {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix && !windows
+
+package bolter
+
+import "os"
+
+// File locking is not available on this platform; downloads are still
+// written atomically, but concurrent callers may download the same entry.
+
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,389 @@
+package bolter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gopkg.in/yaml.v3"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// AnyPlatform is the platform key of a LockedTool that points to a single
+// manifest instead of an index
+const AnyPlatform = "*"
+
+// Lockfile pins every tool of a Project to the digests of its binaries
+type Lockfile struct {
+	// Tools by name
+	Tools map[string]LockedTool `yaml:"tools"`
+}
+
+// LockedTool is a tool resolved to a digest
+type LockedTool struct {
+	// Reference as declared in bolter.yaml, including any version range
+	Reference string `yaml:"ref"`
+	// Resolved is the concrete reference the tool resolved to
+	Resolved string `yaml:"resolved"`
+	// Digest of the index or manifest
+	Digest string `yaml:"digest"`
+	// MediaType of the index or manifest
+	MediaType string `yaml:"mediaType"`
+	// Size of the index or manifest in bytes
+	Size int64 `yaml:"size"`
+	// Platforms by "os/arch[/variant]", or AnyPlatform for single manifests
+	Platforms map[string]LockedPlatform `yaml:"platforms"`
+}
+
+// LockedPlatform pins the binary of a tool for one platform
+type LockedPlatform struct {
+	// Manifest is the digest of the platform manifest
+	Manifest string `yaml:"manifest"`
+	// ManifestSize is the size of the platform manifest in bytes
+	ManifestSize int64 `yaml:"manifestSize"`
+	// Digest of the binary
+	Digest string `yaml:"digest"`
+	// Size of the binary in bytes
+	Size int64 `yaml:"size"`
+}
+
+// LockOptions configures the Lock operation
+type LockOptions struct {
+	// Username for registry authentication
+	Username string
+	// Password for registry authentication
+	Password string
+	// Insecure allows insecure registry connections
+	Insecure bool
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+}
+
+// InstallOptions configures the Install operation
+type InstallOptions struct {
+	// Platform to install (e.g., "linux/amd64"). Defaults to current platform.
+	Platform string
+	// Username for registry authentication
+	Username string
+	// Password for registry authentication
+	Password string
+	// Insecure allows insecure registry connections
+	Insecure bool
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+	// CacheDir overrides the default cache directory (~/.cache/bolter)
+	CacheDir string
+	// Dir, if set, receives a copy of every binary named after its tool
+	Dir string
+	// Tools limits the installation to the named tools. Empty installs all.
+	Tools []string
+}
+
+// LoadLockfile reads a bolter.lock
+func LoadLockfile(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lockfile Lockfile
+	if err := yaml.Unmarshal(data, &lockfile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for name, tool := range lockfile.Tools {
+		if _, err := digest.Parse(tool.Digest); err != nil {
+			return nil, fmt.Errorf("%s: tool %s: %w", path, name, err)
+		}
+	}
+
+	return &lockfile, nil
+}
+
+// Save writes the lockfile to path
+func (l *Lockfile) Save(path string) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by bolter lock. DO NOT EDIT.\n")
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(l); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, buf.Bytes(), 0644)
+}
+
+// Reference returns the digest reference a locked tool is pinned to
+func (l *Lockfile) Reference(name string) (string, error) {
+	tool, ok := l.Tools[name]
+	if !ok {
+		return "", fmt.Errorf("tool %s is not in %s", name, LockFile)
+	}
+
+	ref, err := parseReference(tool.Resolved)
+	if err != nil {
+		return "", fmt.Errorf("tool %s: %w", name, err)
+	}
+	ref.Reference = tool.Digest
+
+	return ref.String(), nil
+}
+
+// Lock resolves every tool of project to a digest for every platform it is
+// available for
+func Lock(ctx context.Context, project *Project, opts LockOptions) (_ *Lockfile, err error) {
+	defer func() { err = classifyError(err) }()
+
+	lockfile := &Lockfile{Tools: make(map[string]LockedTool)}
+
+	for _, name := range sortedToolNames(project.Tools) {
+		tool := project.Tools[name]
+
+		logger(opts.Logger).Info("Locking tool", "tool", name, "ref", tool.ref())
+
+		locked, err := lockTool(ctx, tool, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock %s: %w", name, err)
+		}
+		lockfile.Tools[name] = *locked
+	}
+
+	return lockfile, nil
+}
+
+func lockTool(ctx context.Context, tool Tool, opts LockOptions) (*LockedTool, error) {
+	ref := tool.ref()
+	base, rng, isRange := splitVersionRange(ref)
+	if isRange {
+		ref = base
+	}
+
+	repo, err := createRepository(ref, opts.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	// Setup authentication
+	if err := setupAuth(repo, opts.Username, opts.Password, logger(opts.Logger)); err != nil {
+		return nil, err
+	}
+
+	if isRange {
+		tag, err := resolveVersionRange(ctx, repo, rng, tool.Prerelease)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve version range: %w", err)
+		}
+		repo.Reference.Reference = tag
+	}
+
+	descriptor, err := repo.Resolve(ctx, repo.Reference.Reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reference: %w", err)
+	}
+
+	locked := &LockedTool{
+		Reference: tool.ref(),
+		Resolved:  repo.Reference.String(),
+		Digest:    descriptor.Digest.String(),
+		MediaType: descriptor.MediaType,
+		Size:      descriptor.Size,
+		Platforms: make(map[string]LockedPlatform),
+	}
+
+	switch descriptor.MediaType {
+	case ocispec.MediaTypeImageIndex:
+		data, err := fetchContent(ctx, repo, "", descriptor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", descriptor.Digest, err)
+		}
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, err
+		}
+		for _, manifestDesc := range index.Manifests {
+			if manifestDesc.Platform == nil {
+				continue
+			}
+			platform, err := lockPlatform(ctx, repo, manifestDesc)
+			if err != nil {
+				return nil, err
+			}
+			locked.Platforms[platformString(normalizePlatform(*manifestDesc.Platform))] = *platform
+		}
+	case ocispec.MediaTypeImageManifest:
+		platform, err := lockPlatform(ctx, repo, descriptor)
+		if err != nil {
+			return nil, err
+		}
+		locked.Platforms[AnyPlatform] = *platform
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, descriptor.MediaType)
+	}
+
+	return locked, nil
+}
+
+func lockPlatform(ctx context.Context, repo *remote.Repository, manifestDesc ocispec.Descriptor) (*LockedPlatform, error) {
+	layerDesc, err := resolveLayer(ctx, repo, "", manifestDesc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest %s: %w", manifestDesc.Digest, err)
+	}
+
+	return &LockedPlatform{
+		Manifest:     manifestDesc.Digest.String(),
+		ManifestSize: manifestDesc.Size,
+		Digest:       layerDesc.Digest.String(),
+		Size:         layerDesc.Size,
+	}, nil
+}
+
+// Install pulls the binaries pinned by the lockfile into the cache.
+// It fails if the content in the registry does not match the lockfile.
+func Install(ctx context.Context, lockfile *Lockfile, opts InstallOptions) (_ []BinaryInfo, err error) {
+	defer func() { err = classifyError(err) }()
+
+	target := parsePlatform(opts.Platform)
+
+	cacheDir, err := getCacheDir(opts.CacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory: %w", err)
+	}
+
+	names := opts.Tools
+	if len(names) == 0 {
+		names = sortedToolNames(lockfile.Tools)
+	}
+
+	var installed []BinaryInfo
+	for _, name := range names {
+		tool, ok := lockfile.Tools[name]
+		if !ok {
+			return nil, fmt.Errorf("tool %s is not in %s", name, LockFile)
+		}
+
+		logger(opts.Logger).Info("Installing tool", "tool", name, "ref", tool.Resolved, "digest", tool.Digest)
+
+		info, err := installTool(ctx, cacheDir, name, tool, target, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to install %s: %w", name, err)
+		}
+		installed = append(installed, *info)
+	}
+
+	return installed, nil
+}
+
+func installTool(ctx context.Context, cacheDir, name string, tool LockedTool, target ocispec.Platform, opts InstallOptions) (*BinaryInfo, error) {
+	repo, err := createRepository(tool.Resolved, opts.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	// Setup authentication
+	if err := setupAuth(repo, opts.Username, opts.Password, logger(opts.Logger)); err != nil {
+		return nil, err
+	}
+
+	// Content is fetched by digest, so only the exact locked content is accepted
+	descriptor := ocispec.Descriptor{
+		MediaType: tool.MediaType,
+		Digest:    digest.Digest(tool.Digest),
+		Size:      tool.Size,
+	}
+
+	manifestDesc, err := selectManifest(ctx, repo, cacheDir, descriptor, target)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := manifestPlatform(manifestDesc, target)
+	key := platformString(selected)
+	if tool.MediaType == ocispec.MediaTypeImageManifest {
+		key = AnyPlatform
+	}
+	platform, ok := tool.Platforms[key]
+	if !ok {
+		return nil, fmt.Errorf("no binary locked for %s", key)
+	}
+	if manifestDesc.Digest.String() != platform.Manifest {
+		return nil, fmt.Errorf("manifest does not match %s: %w", LockFile, &DigestMismatchError{
+			Expected:     digest.Digest(platform.Manifest),
+			Actual:       manifestDesc.Digest,
+			ExpectedSize: -1,
+		})
+	}
+
+	layerDesc, err := resolveLayer(ctx, repo, cacheDir, manifestDesc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull binary: %w", err)
+	}
+	if layerDesc.Digest.String() != platform.Digest || layerDesc.Size != platform.Size {
+		return nil, fmt.Errorf("binary does not match %s: %w", LockFile, &DigestMismatchError{
+			Expected:     digest.Digest(platform.Digest),
+			Actual:       layerDesc.Digest,
+			ExpectedSize: platform.Size,
+			ActualSize:   layerDesc.Size,
+		})
+	}
+
+	blobPath, cached, err := fetchBlobToCache(ctx, repo, cacheDir, layerDesc, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull binary: %w", err)
+	}
+
+	repo.Reference.Reference = tool.Digest
+	if err := saveCacheMetadata(cacheDir, repo.Reference, selected, manifestDesc, layerDesc); err != nil {
+		logger(opts.Logger).Warn("Failed to save cache metadata", "tool", name, "error", err)
+	}
+
+	outputPath := blobPath
+	if opts.Dir != "" {
+		outputPath = filepath.Join(opts.Dir, name)
+		if selected.OS == "windows" {
+			outputPath += ".exe"
+		}
+		if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+			return nil, err
+		}
+		if err := copyFile(blobPath, outputPath); err != nil {
+			return nil, fmt.Errorf("failed to copy binary from cache: %w", err)
+		}
+	}
+
+	var tag string
+	if resolved, err := parseReference(tool.Resolved); err == nil && !isDigestReference(resolved) {
+		tag = resolved.Reference
+	}
+
+	return &BinaryInfo{
+		Path:         outputPath,
+		Reference:    tool.Resolved,
+		Tag:          tag,
+		Digest:       manifestDesc.Digest.String(),
+		Size:         layerDesc.Size,
+		OS:           selected.OS,
+		Architecture: selected.Architecture,
+		Variant:      selected.Variant,
+		Cached:       cached,
+	}, nil
+}
+
+func sortedToolNames[T any](tools map[string]T) []string {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
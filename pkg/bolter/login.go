@@ -0,0 +1,84 @@
+package bolter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aep/bolter/pkg/credentials"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// LoginOptions configures the Login operation
+type LoginOptions struct {
+	// Username for registry authentication
+	Username string
+	// Password for registry authentication
+	Password string
+	// Insecure allows insecure registry connections
+	Insecure bool
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+}
+
+// Login checks the credentials against the registry and saves them for
+// later use by Push, Pull and Run. It returns where they were stored.
+func Login(ctx context.Context, registryName string, opts LoginOptions) (_ string, err error) {
+	defer func() { err = classifyError(err) }()
+
+	registryName = normalizeRegistryName(registryName)
+
+	if opts.Username == "" || opts.Password == "" {
+		return "", fmt.Errorf("username and password are required")
+	}
+
+	reg, err := remote.NewRegistry(registryName)
+	if err != nil {
+		return "", fmt.Errorf("invalid registry: %w", err)
+	}
+	reg.PlainHTTP = opts.Insecure
+
+	cred := credentials.Credential{
+		Username: opts.Username,
+		Password: opts.Password,
+	}
+
+	reg.Client = &auth.Client{
+		Client:     retry.DefaultClient,
+		Cache:      auth.NewCache(),
+		Credential: auth.StaticCredential(reg.Reference.Registry, authCredential(cred)),
+	}
+
+	logger(opts.Logger).Info("Checking credentials", "registry", reg.Reference.Host())
+
+	if err := reg.Ping(ctx); err != nil {
+		return "", fmt.Errorf("failed to check credentials: %w", err)
+	}
+
+	location, err := credentials.Store(registryName, cred)
+	if err != nil {
+		return "", fmt.Errorf("failed to store credentials: %w", err)
+	}
+
+	return location, nil
+}
+
+// Logout removes the credentials saved for registry
+func Logout(registryName string) error {
+	return credentials.Erase(normalizeRegistryName(registryName))
+}
+
+// normalizeRegistryName strips the scheme and path a user may paste along
+// with a registry host
+func normalizeRegistryName(registryName string) string {
+	registryName = strings.TrimPrefix(registryName, "https://")
+	registryName = strings.TrimPrefix(registryName, "http://")
+	registryName, _, _ = strings.Cut(registryName, "/")
+	if registryName == "index.docker.io" || registryName == "registry-1.docker.io" {
+		return "docker.io"
+	}
+	return registryName
+}
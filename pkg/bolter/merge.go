@@ -0,0 +1,206 @@
+package bolter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// maxMergeAttempts is how often a merge is retried when the index is
+// changed concurrently by another push
+const maxMergeAttempts = 8
+
+// mergeAndTagIndex adds manifests to the index currently tagged as the
+// reference of repo, replacing entries for the same platforms, and tags
+// the result. Registries have no compare-and-swap for tags, so the update
+// is optimistic: it is only tagged if the tag still points to the index
+// the merge started from, and once concurrent writers that passed the same
+// check had time to tag, the tag is checked again to make sure none of them
+// dropped the new manifests. Either conflict restarts the merge from the
+// current index. If immutable is set, a tag that exists and points to a
+// different index fails with an *ImmutableTagError instead.
+func mergeAndTagIndex(ctx context.Context, repo *remote.Repository, manifests []ocispec.Descriptor, immutable bool, log *slog.Logger) (ocispec.Descriptor, error) {
+	tag := repo.Reference.Reference
+
+	for attempt := 1; ; attempt++ {
+		base, existing, err := fetchIndexManifests(ctx, repo, tag)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+
+		if base == "" {
+			log.Info("No existing index, creating one", "tag", tag)
+		} else {
+			log.Info("Merging into existing index", "tag", tag, "digest", base, "platforms", len(existing))
+		}
+
+		indexDesc, err := createAndPushIndex(ctx, repo, mergeManifests(existing, manifests))
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to create manifest index: %w", err)
+		}
+
+		checkedAt := time.Now()
+		current, err := resolveTagDigest(ctx, repo, tag)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		if immutable && current != "" && current != indexDesc.Digest {
+			return ocispec.Descriptor{}, &ImmutableTagError{Tag: tag, Old: current, New: indexDesc.Digest}
+		}
+
+		if current == base {
+			if err := repo.Tag(ctx, indexDesc, tag); err != nil {
+				return ocispec.Descriptor{}, fmt.Errorf("failed to tag manifest: %w", err)
+			}
+
+			// A concurrent writer that checked the same base before we
+			// tagged may still tag its index, dropping our manifests. Give
+			// it about as long as our own check and tag took.
+			if err := sleepContext(ctx, 2*time.Since(checkedAt)+200*time.Millisecond); err != nil {
+				return ocispec.Descriptor{}, err
+			}
+
+			latest, latestManifests, err := fetchIndexManifests(ctx, repo, tag)
+			if err != nil {
+				return ocispec.Descriptor{}, err
+			}
+			if latest == indexDesc.Digest {
+				return indexDesc, nil
+			}
+			if containsManifests(latestManifests, manifests) {
+				return resolveTag(ctx, repo, tag)
+			}
+		}
+
+		if attempt == maxMergeAttempts {
+			return ocispec.Descriptor{}, fmt.Errorf("index %s was changed concurrently, giving up after %d attempts", tag, attempt)
+		}
+
+		log.Info("Index changed concurrently, retrying", "tag", tag, "attempt", attempt, "max_attempts", maxMergeAttempts)
+
+		// Back off with jitter so that concurrent writers do not collide again
+		delay := time.Duration(attempt)*100*time.Millisecond + rand.N(250*time.Millisecond)
+		if err := sleepContext(ctx, delay); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// fetchIndexManifests returns the digest and manifests of the index tagged
+// as tag. A missing tag yields an empty digest and no manifests.
+func fetchIndexManifests(ctx context.Context, repo *remote.Repository, tag string) (digest.Digest, []ocispec.Descriptor, error) {
+	desc, err := repo.Resolve(ctx, tag)
+	if errors.Is(err, errdef.ErrNotFound) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve %s: %w", tag, err)
+	}
+
+	if desc.MediaType != ocispec.MediaTypeImageIndex {
+		return "", nil, fmt.Errorf("cannot merge into %s: it is a %s, not an index", tag, desc.MediaType)
+	}
+
+	data, err := content.FetchAll(ctx, repo, desc)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to fetch index %s: %w", desc.Digest, err)
+	}
+
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return "", nil, err
+	}
+
+	return desc.Digest, index.Manifests, nil
+}
+
+func resolveTag(ctx context.Context, repo *remote.Repository, tag string) (ocispec.Descriptor, error) {
+	desc, err := repo.Resolve(ctx, tag)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to resolve %s: %w", tag, err)
+	}
+	return desc, nil
+}
+
+// resolveTagDigest returns the digest tag points to, or an empty digest if
+// the tag does not exist
+func resolveTagDigest(ctx context.Context, repo *remote.Repository, tag string) (digest.Digest, error) {
+	desc, err := repo.Resolve(ctx, tag)
+	if errors.Is(err, errdef.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", tag, err)
+	}
+	return desc.Digest, nil
+}
+
+// mergeManifests replaces the manifests of existing that have the same
+// platform as one of updates and appends the remaining updates. Entries
+// without a platform are dropped, since they cannot be selected by Pull.
+func mergeManifests(existing, updates []ocispec.Descriptor) []ocispec.Descriptor {
+	byPlatform := make(map[string]ocispec.Descriptor, len(updates))
+	for _, update := range updates {
+		byPlatform[mergeKey(update)] = update
+	}
+
+	var merged []ocispec.Descriptor
+	for _, manifest := range existing {
+		if manifest.Platform == nil {
+			continue
+		}
+		key := mergeKey(manifest)
+		if update, ok := byPlatform[key]; ok {
+			merged = append(merged, update)
+			delete(byPlatform, key)
+		} else {
+			merged = append(merged, manifest)
+		}
+	}
+
+	for _, update := range updates {
+		if _, ok := byPlatform[mergeKey(update)]; ok {
+			merged = append(merged, update)
+		}
+	}
+
+	return merged
+}
+
+func mergeKey(desc ocispec.Descriptor) string {
+	p := normalizePlatform(*desc.Platform)
+	return platformString(p) + "@" + p.OSVersion
+}
+
+// containsManifests reports whether every manifest of want is in manifests
+func containsManifests(manifests, want []ocispec.Descriptor) bool {
+	present := make(map[digest.Digest]bool, len(manifests))
+	for _, manifest := range manifests {
+		present[manifest.Digest] = true
+	}
+	for _, manifest := range want {
+		if !present[manifest.Digest] {
+			return false
+		}
+	}
+	return true
+}
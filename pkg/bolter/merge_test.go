@@ -0,0 +1,110 @@
+package bolter
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestMergeManifests(t *testing.T) {
+	manifest := func(content, platform string) ocispec.Descriptor {
+		p := parsePlatform(platform)
+		return ocispec.Descriptor{Digest: digest.FromString(content), Platform: &p}
+	}
+
+	existing := []ocispec.Descriptor{
+		manifest("linux old", "linux/amd64"),
+		manifest("darwin", "darwin/arm64"),
+		manifest("arm6", "linux/arm/v6"),
+		{Digest: digest.FromString("attestation")},
+	}
+	updates := []ocispec.Descriptor{
+		manifest("windows", "windows/amd64"),
+		manifest("linux new", "linux/x86_64"),
+		manifest("arm7", "linux/arm/v7"),
+	}
+
+	merged := mergeManifests(existing, updates)
+
+	want := []ocispec.Descriptor{
+		manifest("linux new", "linux/x86_64"),
+		manifest("darwin", "darwin/arm64"),
+		manifest("arm6", "linux/arm/v6"),
+		manifest("windows", "windows/amd64"),
+		manifest("arm7", "linux/arm/v7"),
+	}
+	if len(merged) != len(want) {
+		t.Fatalf("merged %d manifests, want %d", len(merged), len(want))
+	}
+	for i := range want {
+		if merged[i].Digest != want[i].Digest {
+			t.Errorf("manifest %d = %s, want %s", i, platformString(*merged[i].Platform), platformString(*want[i].Platform))
+		}
+	}
+
+	if !containsManifests(merged, updates) {
+		t.Error("merged index does not contain the updates")
+	}
+	if containsManifests(existing, updates) {
+		t.Error("existing index should not contain the updates")
+	}
+}
+
+func TestMergeAndTagIndexRetries(t *testing.T) {
+	reg, host := newTestRegistry(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	binary := func(goos, arch string) PlatformBinary {
+		path := filepath.Join(dir, goos+"-"+arch)
+		if err := os.WriteFile(path, []byte(goos+" "+arch+" build"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return PlatformBinary{Path: path, OS: goos, Architecture: arch}
+	}
+	opts := PushOptions{Insecure: true, Merge: true}
+
+	if _, err := Push(ctx, host+"/org/tool:v1", []PlatformBinary{binary("linux", "amd64")}, opts); err != nil {
+		t.Fatal(err)
+	}
+	// The index another writer merges darwin/arm64 into while we merge
+	concurrent, err := Push(ctx, host+"/org/tool:concurrent", []PlatformBinary{binary("linux", "amd64"), binary("darwin", "arm64")}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexPushes := 0
+	reg.onRequest = func(req *http.Request) {
+		if req.Method == http.MethodPut && req.Header.Get("Content-Type") == ocispec.MediaTypeImageIndex {
+			indexPushes++
+			if indexPushes == 1 {
+				reg.tags["org/tool"]["v1"] = concurrent.Digest.String()
+			}
+		}
+	}
+
+	if _, err := Push(ctx, host+"/org/tool:v1", []PlatformBinary{binary("linux", "arm64")}, opts); err != nil {
+		t.Fatal(err)
+	}
+	if indexPushes < 2 {
+		t.Errorf("index was pushed %d times, want a retry", indexPushes)
+	}
+
+	listing, err := List(ctx, host+"/org/tool:v1", ListOptions{Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var platforms []string
+	for _, manifest := range listing.Manifests {
+		platforms = append(platforms, platformString(*manifest.Platform))
+	}
+	if want := []string{"linux/amd64", "darwin/arm64", "linux/arm64"}; !slices.Equal(platforms, want) {
+		t.Errorf("v1 has platforms %v, want %v", platforms, want)
+	}
+}
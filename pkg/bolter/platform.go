@@ -0,0 +1,264 @@
+package bolter
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sys/cpu"
+)
+
+// archAliases maps architecture names used by other tools (uname, Debian,
+// Rust target triples) to GOARCH and, where implied, a variant
+var archAliases = map[string]ocispec.Platform{
+	"x86_64":  {Architecture: "amd64"},
+	"x86-64":  {Architecture: "amd64"},
+	"x64":     {Architecture: "amd64"},
+	"aarch64": {Architecture: "arm64"},
+	"armhf":   {Architecture: "arm", Variant: "v7"},
+	"armv7l":  {Architecture: "arm", Variant: "v7"},
+	"armv7":   {Architecture: "arm", Variant: "v7"},
+	"armel":   {Architecture: "arm", Variant: "v6"},
+	"armv6l":  {Architecture: "arm", Variant: "v6"},
+	"armv6":   {Architecture: "arm", Variant: "v6"},
+	"armv5":   {Architecture: "arm", Variant: "v5"},
+	"i386":    {Architecture: "386"},
+	"i686":    {Architecture: "386"},
+	"x86":     {Architecture: "386"},
+	"ppc64el": {Architecture: "ppc64le"},
+}
+
+// parsePlatform parses a platform in format "os/arch" or "os/arch/variant".
+// An empty platform means the current platform, with the variant left empty
+// so that the best one for the host CPU is picked.
+func parsePlatform(platform string) ocispec.Platform {
+	if platform == "" {
+		return ocispec.Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+	}
+
+	parts := strings.SplitN(platform, "/", 3)
+	p := ocispec.Platform{OS: parts[0], Architecture: runtime.GOARCH}
+	if len(parts) > 1 {
+		p.Architecture = parts[1]
+	}
+	if len(parts) > 2 {
+		p.Variant = parts[2]
+	}
+
+	return normalizePlatform(p)
+}
+
+// normalizePlatform resolves architecture aliases and drops variants that
+// are equivalent to none (amd64/v1, arm64/v8)
+func normalizePlatform(p ocispec.Platform) ocispec.Platform {
+	p.OS = strings.ToLower(p.OS)
+	p.Architecture = strings.ToLower(p.Architecture)
+	p.Variant = strings.ToLower(p.Variant)
+
+	if alias, ok := archAliases[p.Architecture]; ok {
+		p.Architecture = alias.Architecture
+		if p.Variant == "" {
+			p.Variant = alias.Variant
+		}
+	}
+
+	switch {
+	case p.Architecture == "amd64" && p.Variant == "v1",
+		p.Architecture == "arm64" && p.Variant == "v8":
+		p.Variant = ""
+	}
+
+	return p
+}
+
+// platformString formats p as "os/arch" or "os/arch/variant"
+func platformString(p ocispec.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// variantPreference returns the variants of target's architecture that can
+// run on it, best first. An empty string stands for the baseline build.
+//
+// Without an explicit variant, amd64 hosts prefer the highest
+// micro-architecture level their CPU supports (v4, v3, v2) and arm hosts
+// the highest ARM version. For other machines nothing is known about the
+// CPU, so amd64 prefers the baseline build and arm defaults to v7.
+func variantPreference(target ocispec.Platform) []string {
+	isHost := target.OS == runtime.GOOS && target.Architecture == runtime.GOARCH
+
+	var highest, lowest int
+	switch target.Architecture {
+	case "amd64":
+		if target.Variant == "" && !isHost {
+			return []string{"", "v2", "v3", "v4"}
+		}
+		highest, lowest = 4, 2
+		if target.Variant != "" {
+			highest = variantNumber(target.Variant)
+		} else {
+			highest = hostAMD64Level()
+		}
+	case "arm":
+		highest, lowest = 7, 5
+		if target.Variant != "" {
+			highest = variantNumber(target.Variant)
+		} else if isHost {
+			highest = hostARMVersion()
+		}
+	default:
+		if target.Variant != "" {
+			return []string{target.Variant, ""}
+		}
+		return []string{""}
+	}
+
+	var variants []string
+	for v := highest; v >= lowest; v-- {
+		variants = append(variants, "v"+strconv.Itoa(v))
+	}
+	return append(variants, "")
+}
+
+func variantNumber(variant string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(variant, "v"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// hostAMD64Level returns the x86-64 micro-architecture level of the host CPU
+func hostAMD64Level() int {
+	x := cpu.X86
+	switch {
+	case !(x.HasSSE3 && x.HasSSSE3 && x.HasSSE41 && x.HasSSE42 && x.HasPOPCNT && x.HasCX16):
+		return 1
+	case !(x.HasAVX && x.HasAVX2 && x.HasBMI1 && x.HasBMI2 && x.HasFMA && x.HasOSXSAVE):
+		return 2
+	case !(x.HasAVX512F && x.HasAVX512BW && x.HasAVX512CD && x.HasAVX512DQ && x.HasAVX512VL):
+		return 3
+	default:
+		return 4
+	}
+}
+
+// hostARMVersion returns the ARM architecture version of the host CPU
+func hostARMVersion() int {
+	if cpu.ARM.HasVFPv3 {
+		return 7
+	}
+	return 6
+}
+
+// compareOSVersion compares dotted version numbers such as "10.0.17763"
+func compareOSVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// findManifestForPlatform picks the manifest of index that best fits
+// target: variants are tried in the order of variantPreference, and among
+// manifests with an os.version the newest one the host can run is used.
+func findManifestForPlatform(index ocispec.Index, target ocispec.Platform) (*ocispec.Descriptor, error) {
+	target = normalizePlatform(target)
+
+	maxOSVersion := target.OSVersion
+	if maxOSVersion == "" && target.OS == runtime.GOOS {
+		maxOSVersion = hostOSVersion()
+	}
+
+	for _, variant := range variantPreference(target) {
+		var best *ocispec.Descriptor
+		var bestOSVersion string
+
+		for i, manifest := range index.Manifests {
+			if manifest.Platform == nil {
+				continue
+			}
+			p := normalizePlatform(*manifest.Platform)
+			if p.OS != target.OS || p.Architecture != target.Architecture || p.Variant != variant {
+				continue
+			}
+			if maxOSVersion != "" && p.OSVersion != "" && compareOSVersion(p.OSVersion, maxOSVersion) > 0 {
+				continue
+			}
+			if best == nil || compareOSVersion(p.OSVersion, bestOSVersion) > 0 {
+				best, bestOSVersion = &index.Manifests[i], p.OSVersion
+			}
+		}
+
+		if best != nil {
+			return best, nil
+		}
+	}
+
+	notFound := &PlatformNotFoundError{Platform: target}
+	for _, manifest := range index.Manifests {
+		if manifest.Platform != nil {
+			notFound.Available = append(notFound.Available, normalizePlatform(*manifest.Platform))
+		}
+	}
+
+	return nil, notFound
+}
+
+// findExactManifests returns the manifests of index whose normalized
+// os/arch/variant equals target, ignoring the host. Unlike
+// findManifestForPlatform no other variant is substituted, so that
+// selecting platforms gives the same result on every machine. All
+// os.versions of the platform are returned.
+func findExactManifests(index ocispec.Index, target ocispec.Platform) ([]ocispec.Descriptor, error) {
+	target = normalizePlatform(target)
+
+	var manifests []ocispec.Descriptor
+	for _, manifest := range index.Manifests {
+		if manifest.Platform == nil {
+			continue
+		}
+		p := normalizePlatform(*manifest.Platform)
+		if p.OS == target.OS && p.Architecture == target.Architecture && p.Variant == target.Variant {
+			manifests = append(manifests, manifest)
+		}
+	}
+
+	if len(manifests) == 0 {
+		notFound := &PlatformNotFoundError{Platform: target}
+		for _, manifest := range index.Manifests {
+			if manifest.Platform != nil {
+				notFound.Available = append(notFound.Available, normalizePlatform(*manifest.Platform))
+			}
+		}
+		return nil, notFound
+	}
+
+	return manifests, nil
+}
+
+// manifestPlatform returns the platform of a selected manifest, falling back
+// to target for single manifests without platform information
+func manifestPlatform(manifestDesc ocispec.Descriptor, target ocispec.Platform) ocispec.Platform {
+	if manifestDesc.Platform != nil {
+		return normalizePlatform(*manifestDesc.Platform)
+	}
+	return normalizePlatform(target)
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package bolter
+
+// hostOSVersion returns the OS version to match against os.version of
+// manifests. Only Windows binaries are published per OS version.
+func hostOSVersion() string {
+	return ""
+}
@@ -0,0 +1,117 @@
+package bolter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := map[string]string{
+		"linux/amd64":     "linux/amd64",
+		"linux/x86_64":    "linux/amd64",
+		"linux/amd64/v1":  "linux/amd64",
+		"linux/amd64/v3":  "linux/amd64/v3",
+		"linux/aarch64":   "linux/arm64",
+		"linux/arm64/v8":  "linux/arm64",
+		"linux/armhf":     "linux/arm/v7",
+		"linux/armel":     "linux/arm/v6",
+		"linux/arm/v6":    "linux/arm/v6",
+		"Linux/ARMv7l":    "linux/arm/v7",
+		"windows/i686":    "windows/386",
+		"linux/ppc64el":   "linux/ppc64le",
+		"darwin/arm64/v8": "darwin/arm64",
+	}
+
+	for in, want := range tests {
+		if got := platformString(parsePlatform(in)); got != want {
+			t.Errorf("parsePlatform(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestFindManifestForPlatform(t *testing.T) {
+	manifest := func(name, platform, osVersion string) ocispec.Descriptor {
+		p := parsePlatform(platform)
+		p.OSVersion = osVersion
+		return ocispec.Descriptor{Digest: digest.Digest("sha256:" + name), Platform: &p}
+	}
+
+	tests := []struct {
+		name      string
+		manifests []ocispec.Descriptor
+		target    ocispec.Platform
+		want      string
+	}{
+		{
+			name:      "exact arm variant",
+			manifests: []ocispec.Descriptor{manifest("v6", "linux/arm/v6", ""), manifest("v7", "linux/arm/v7", "")},
+			target:    parsePlatform("linux/arm/v6"),
+			want:      "v6",
+		},
+		{
+			name:      "arm falls back to older variant",
+			manifests: []ocispec.Descriptor{manifest("v6", "linux/arm/v6", "")},
+			target:    parsePlatform("linux/armhf"),
+			want:      "v6",
+		},
+		{
+			name:      "arm never picks newer variant",
+			manifests: []ocispec.Descriptor{manifest("v7", "linux/arm/v7", "")},
+			target:    parsePlatform("linux/arm/v6"),
+		},
+		{
+			name:      "amd64 v3 falls back to v2",
+			manifests: []ocispec.Descriptor{manifest("base", "linux/amd64", ""), manifest("v2", "linux/amd64/v2", "")},
+			target:    parsePlatform("linux/amd64/v3"),
+			want:      "v2",
+		},
+		{
+			name:      "amd64 of another machine prefers the baseline",
+			manifests: []ocispec.Descriptor{manifest("v3", "plan9/amd64/v3", ""), manifest("base", "plan9/amd64", "")},
+			target:    parsePlatform("plan9/amd64"),
+			want:      "base",
+		},
+		{
+			name:      "aliases in the index",
+			manifests: []ocispec.Descriptor{{Digest: "sha256:alias", Platform: &ocispec.Platform{OS: "linux", Architecture: "aarch64"}}},
+			target:    parsePlatform("linux/arm64"),
+			want:      "alias",
+		},
+		{
+			name: "newest compatible os.version",
+			manifests: []ocispec.Descriptor{
+				manifest("ltsc2019", "windows/amd64", "10.0.17763"),
+				manifest("ltsc2022", "windows/amd64", "10.0.20348"),
+				manifest("future", "windows/amd64", "10.0.99999"),
+			},
+			target: ocispec.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.26100"},
+			want:   "ltsc2022",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findManifestForPlatform(ocispec.Index{Manifests: tt.manifests}, tt.target)
+			if tt.want == "" {
+				var notFound *PlatformNotFoundError
+				if !errors.As(err, &notFound) {
+					t.Fatalf("expected PlatformNotFoundError, got %v, %v", got, err)
+				}
+				if !errors.Is(err, ErrPlatformNotFound) || len(notFound.Available) != len(tt.manifests) {
+					t.Errorf("unexpected error %#v", notFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Digest.Encoded() != tt.want {
+				t.Errorf("got %s, want %s", got.Digest.Encoded(), tt.want)
+			}
+		})
+	}
+}
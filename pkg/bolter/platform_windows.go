@@ -0,0 +1,15 @@
+//go:build windows
+
+package bolter
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// hostOSVersion returns the Windows version as "major.minor.build"
+func hostOSVersion() string {
+	v := windows.RtlGetVersion()
+	return fmt.Sprintf("%d.%d.%d", v.MajorVersion, v.MinorVersion, v.BuildNumber)
+}
@@ -0,0 +1,133 @@
+package bolter
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// EventType is the kind of a progress Event
+type EventType int
+
+const (
+	// EventStart is sent when a transfer begins
+	EventStart EventType = iota
+	// EventProgress is sent periodically while bytes are transferred
+	EventProgress
+	// EventSkip is sent instead of a transfer when the content is already
+	// present at the destination
+	EventSkip
+	// EventDone is sent when a transfer completed
+	EventDone
+	// EventError is sent when a transfer failed
+	EventError
+)
+
+// Event reports the progress of transferring a binary
+type Event struct {
+	// Type of the event
+	Type EventType
+	// Platform of the binary being transferred (e.g., "linux/amd64")
+	Platform string
+	// Digest of the binary
+	Digest digest.Digest
+	// Current is the number of bytes transferred so far
+	Current int64
+	// Total is the size of the binary in bytes
+	Total int64
+	// Err is set for EventError
+	Err error
+}
+
+// progressInterval limits how often EventProgress is sent per transfer
+const progressInterval = 100 * time.Millisecond
+
+// progressReporter sends the events of a single transfer. A reporter with
+// a nil callback does nothing.
+type progressReporter struct {
+	fn       func(Event)
+	platform string
+	digest   digest.Digest
+	total    int64
+
+	mu       sync.Mutex
+	lastSent time.Time
+}
+
+func newProgressReporter(fn func(Event), platform string, dgst digest.Digest, total int64) *progressReporter {
+	return &progressReporter{fn: fn, platform: platform, digest: dgst, total: total}
+}
+
+func (p *progressReporter) send(typ EventType, current int64, err error) {
+	if p == nil || p.fn == nil {
+		return
+	}
+	p.fn(Event{Type: typ, Platform: p.platform, Digest: p.digest, Current: current, Total: p.total, Err: err})
+}
+
+// update sends an EventProgress unless one was sent very recently
+func (p *progressReporter) update(current int64) {
+	if p == nil || p.fn == nil {
+		return
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	if now.Sub(p.lastSent) < progressInterval && current < p.total {
+		p.mu.Unlock()
+		return
+	}
+	p.lastSent = now
+	p.mu.Unlock()
+
+	p.send(EventProgress, current, nil)
+}
+
+// reader returns a reader that reports the bytes read from r, counting
+// from offset
+func (p *progressReporter) reader(r io.Reader, offset int64) io.Reader {
+	if p == nil || p.fn == nil {
+		return r
+	}
+	return &progressReader{r: r, p: p, current: offset}
+}
+
+type progressReader struct {
+	r        io.Reader
+	p        *progressReporter
+	current  int64
+	reported int64
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.current += int64(n)
+		if r.current > r.reported {
+			r.reported = r.current
+			r.p.update(r.current)
+		}
+	}
+	return n, err
+}
+
+// rewind restarts the count at offset after the underlying reader was
+// rewound. Nothing is reported until the bytes read before are passed.
+func (r *progressReader) rewind(offset int64) {
+	r.current = offset
+}
+
+// serializeProgress wraps fn so that it is never called concurrently
+func serializeProgress(fn func(Event)) func(Event) {
+	if fn == nil {
+		return nil
+	}
+	var mu sync.Mutex
+	return func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		fn(e)
+	}
+}
@@ -0,0 +1,135 @@
+package bolter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ProjectFile is the name of the project toolchain manifest
+	ProjectFile = "bolter.yaml"
+	// LockFile is the name of the lockfile written next to the manifest
+	LockFile = "bolter.lock"
+)
+
+// ErrProjectNotFound is returned when no bolter.yaml is found
+var ErrProjectNotFound = errors.New("no " + ProjectFile + " found")
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Project is a set of tools declared in a bolter.yaml:
+//
+//	tools:
+//	  golangci-lint: ghcr.io/org/golangci-lint@^1.55
+//	  protoc:
+//	    ref: ghcr.io/org/protoc
+//	    version: ~25.1
+//	    pre: true
+type Project struct {
+	// Tools by name
+	Tools map[string]Tool `yaml:"tools"`
+
+	// path of the bolter.yaml the project was loaded from
+	path string
+}
+
+// Tool is a single tool of a Project
+type Tool struct {
+	// Reference to the tool, optionally with a tag, digest or version range
+	Reference string `yaml:"ref"`
+	// Version is a version range appended to Reference (e.g., "^1.4")
+	Version string `yaml:"version,omitempty"`
+	// Prerelease allows the version range to resolve to pre-release tags
+	Prerelease bool `yaml:"pre,omitempty"`
+}
+
+// UnmarshalYAML accepts either a plain reference or a mapping
+func (t *Tool) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&t.Reference)
+	}
+
+	type plain Tool
+	return node.Decode((*plain)(t))
+}
+
+// ref returns the reference to resolve, including the version range
+func (t Tool) ref() string {
+	if t.Version == "" {
+		return t.Reference
+	}
+	return t.Reference + "@" + t.Version
+}
+
+// LoadProject reads a bolter.yaml
+func LoadProject(path string) (*Project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var project Project
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for name, tool := range project.Tools {
+		if !toolNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%s: invalid tool name %q", path, name)
+		}
+		if tool.Reference == "" {
+			return nil, fmt.Errorf("%s: tool %s has no ref", path, name)
+		}
+		if tool.Version != "" {
+			if _, err := parseVersionRange(tool.Version); err != nil {
+				return nil, fmt.Errorf("%s: tool %s: %w", path, name, err)
+			}
+		}
+	}
+
+	project.path = path
+	return &project, nil
+}
+
+// FindProject returns the path of the bolter.yaml in dir or the closest
+// parent directory that has one
+func FindProject(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrProjectNotFound
+		}
+		dir = parent
+	}
+}
+
+// Path returns the path of the bolter.yaml
+func (p *Project) Path() string {
+	return p.path
+}
+
+// LockPath returns the path of the bolter.lock belonging to the project
+func (p *Project) LockPath() string {
+	return filepath.Join(filepath.Dir(p.path), LockFile)
+}
+
+// IsToolName reports whether s can name a tool in a bolter.yaml, as opposed
+// to being a registry reference
+func IsToolName(s string) bool {
+	return toolNamePattern.MatchString(s)
+}
@@ -0,0 +1,171 @@
+package bolter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+func TestLoadProject(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ProjectFile)
+	data := `tools:
+  lint: ghcr.io/org/lint@^1.55
+  protoc:
+    ref: ghcr.io/org/protoc
+    version: ~25.1
+    pre: true
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := LoadProject(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Tool{
+		"lint":   {Reference: "ghcr.io/org/lint@^1.55"},
+		"protoc": {Reference: "ghcr.io/org/protoc", Version: "~25.1", Prerelease: true},
+	}
+	if !reflect.DeepEqual(project.Tools, want) {
+		t.Errorf("tools = %+v, want %+v", project.Tools, want)
+	}
+	if got := project.Tools["protoc"].ref(); got != "ghcr.io/org/protoc@~25.1" {
+		t.Errorf("ref() = %q", got)
+	}
+	if got := project.LockPath(); got != filepath.Join(dir, LockFile) {
+		t.Errorf("LockPath() = %q", got)
+	}
+
+	// The project is found from subdirectories
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	found, err := FindProject(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != path {
+		t.Errorf("FindProject() = %q, want %q", found, path)
+	}
+}
+
+func TestLoadProjectInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"tool name": "tools:\n  org/lint: ghcr.io/org/lint\n",
+		"no ref":    "tools:\n  lint:\n    version: ^1\n",
+		"range":     "tools:\n  lint:\n    ref: ghcr.io/org/lint\n    version: ^one\n",
+	} {
+		path := filepath.Join(t.TempDir(), ProjectFile)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadProject(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLockfileRoundTrip(t *testing.T) {
+	lockfile := &Lockfile{Tools: map[string]LockedTool{
+		"lint": {
+			Reference: "ghcr.io/org/lint@^1.55",
+			Resolved:  "ghcr.io/org/lint:v1.55.2",
+			Digest:    "sha256:90e69000e156b09c30e78a83aec20e72449cdabf9791fbcc7c3412cc8765ab04",
+			MediaType: "application/vnd.oci.image.index.v1+json",
+			Size:      289,
+			Platforms: map[string]LockedPlatform{
+				"linux/amd64": {
+					Manifest:     "sha256:f021c24db49b8f098a62df91440c66dc0c09420b2d04b14aafcc9f150ab3e527",
+					ManifestSize: 447,
+					Digest:       "sha256:adac9b5fbccb00734a02ac93f08deab3352ef1d53abbc90837c3b71aff84d77e",
+					Size:         19,
+				},
+			},
+		},
+	}}
+
+	path := filepath.Join(t.TempDir(), LockFile)
+	if err := lockfile.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadLockfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, lockfile) {
+		t.Errorf("loaded = %+v, want %+v", loaded, lockfile)
+	}
+
+	ref, err := loaded.Reference("lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ghcr.io/org/lint@sha256:90e69000e156b09c30e78a83aec20e72449cdabf9791fbcc7c3412cc8765ab04"; ref != want {
+		t.Errorf("Reference() = %q, want %q", ref, want)
+	}
+
+	if _, err := loaded.Reference("missing"); err == nil {
+		t.Error("expected error for unknown tool")
+	}
+}
+
+func TestLockInstallAndRunTool(t *testing.T) {
+	ctx := context.Background()
+	_, host := newTestRegistry(t)
+
+	pushTestBinary(t, host+"/org/tool:v1.2.0", "old build")
+	pushTestBinary(t, host+"/org/tool:v1.3.0", "new build")
+
+	project := &Project{Tools: map[string]Tool{
+		"tool": {Reference: host + "/org/tool", Version: "^1.2"},
+	}}
+	lockfile, err := Lock(ctx, project, LockOptions{Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := lockfile.Tools["tool"]
+	if locked.Resolved != host+"/org/tool:v1.3.0" || locked.Platforms["linux/amd64"].Digest != digest.FromString("new build").String() {
+		t.Fatalf("locked = %+v", locked)
+	}
+
+	// Running a tool by name runs its locked digest, even with a cold cache
+	ref, err := lockfile.Reference("tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := runBinaryContent(t, ref, RunOptions{CacheDir: t.TempDir()}); got != "new build" {
+		t.Errorf("ran %q, want new build", got)
+	}
+
+	dir := t.TempDir()
+	installed, err := Install(ctx, lockfile, InstallOptions{Platform: "linux/amd64", Insecure: true, CacheDir: t.TempDir(), Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) != 1 || installed[0].Path != filepath.Join(dir, "tool") || installed[0].Tag != "v1.3.0" {
+		t.Errorf("installed = %+v", installed)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "tool")); err != nil || string(data) != "new build" {
+		t.Errorf("installed %q, %v", data, err)
+	}
+
+	// Content in the registry that differs from the lockfile is refused
+	platform := locked.Platforms["linux/amd64"]
+	platform.Digest = digest.FromString("old build").String()
+	locked.Platforms = map[string]LockedPlatform{"linux/amd64": platform}
+	lockfile.Tools["tool"] = locked
+	_, err = Install(ctx, lockfile, InstallOptions{Platform: "linux/amd64", Insecure: true, CacheDir: t.TempDir()})
+	if !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("expected ErrDigestMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,580 @@
+package bolter
+
+import (
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sync/errgroup"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// PushOptions configures the Push operation
+type PushOptions struct {
+	// Username for registry authentication
+	Username string
+	// Password for registry authentication
+	Password string
+	// Insecure allows insecure registry connections
+	Insecure bool
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+	// Force pushes binaries whose declared platform does not match their headers
+	Force bool
+	// ChunkSize is the size of the chunks binaries are uploaded in.
+	// Zero uses DefaultChunkSize; a negative size uploads each binary in a
+	// single streamed request.
+	ChunkSize int64
+	// Merge adds the binaries to the index already tagged as the reference,
+	// replacing only the platforms being pushed, instead of overwriting it
+	Merge bool
+	// Concurrency is the number of binaries uploaded at the same time.
+	// Zero uses DefaultConcurrency.
+	Concurrency int
+	// Progress is called with the upload progress of each binary. Calls
+	// are never concurrent, even when binaries are uploaded in parallel.
+	Progress func(Event)
+	// Tags to apply in addition to the tag of the reference
+	Tags []string
+	// Immutable refuses to move a tag that already exists to a different
+	// digest, failing with an *ImmutableTagError
+	Immutable bool
+	// DryRun digests the binaries and reports the tags that would be set
+	// through OnTag, without uploading anything
+	DryRun bool
+	// OnTag is called for each tag once it is set, or with DryRun, for each
+	// tag that would be set
+	OnTag func(TagUpdate)
+	// Annotations are set on the manifest of every binary, overriding the
+	// annotations filled in by Push: the version from the first tag that
+	// is a semantic version, and the creation time, revision, version and
+	// source from the build information of Go binaries
+	Annotations map[string]string
+	// DefaultAnnotations are set on the manifest of every binary unless
+	// Annotations or the binary itself provide them, such as metadata of
+	// the checkout the binaries were built from
+	DefaultAnnotations map[string]string
+}
+
+// ArtifactType is the artifact type of the manifest of every binary
+const ArtifactType = "application/vnd.bolter.binary.v1"
+
+// TagUpdate describes a tag set by Push
+type TagUpdate struct {
+	// Tag that is set
+	Tag string
+	// Old is the digest the tag pointed to before, empty if it did not exist
+	Old digest.Digest
+	// New is the digest the tag points to after the push
+	New digest.Digest
+}
+
+// DefaultConcurrency is the number of binaries Push uploads at the same time
+const DefaultConcurrency = 4
+
+// PlatformBinary is a binary file to push for a single platform.
+// An empty OS or Architecture is detected from the binary headers.
+type PlatformBinary struct {
+	// Path to the binary file
+	Path string
+	// OS of the binary (e.g., "linux")
+	OS string
+	// Architecture of the binary (e.g., "amd64")
+	Architecture string
+	// Variant of the architecture (e.g., "v7" for arm, "v3" for amd64)
+	Variant string
+	// OSVersion is the minimum OS version the binary requires
+	// (e.g., "10.0.17763" for Windows)
+	OSVersion string
+}
+
+// platform returns the OCI platform of the binary
+func (b PlatformBinary) platform() ocispec.Platform {
+	return ocispec.Platform{
+		OS:           b.OS,
+		Architecture: b.Architecture,
+		Variant:      b.Variant,
+		OSVersion:    b.OSVersion,
+	}
+}
+
+// Push uploads binaries for one or more platforms to an OCI registry,
+// creates a manifest index over them and tags it as the tag of ref and
+// every tag in opts.Tags.
+// The platform of each binary is checked against its headers, and a
+// mismatch fails the push with a *PlatformMismatchError unless opts.Force
+// is set. It returns the descriptor of the pushed index.
+func Push(ctx context.Context, ref string, binaries []PlatformBinary, opts PushOptions) (_ ocispec.Descriptor, err error) {
+	defer func() { err = classifyError(err) }()
+
+	if len(binaries) == 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("no binaries to push")
+	}
+
+	log := logger(opts.Logger)
+
+	binaries, formats, err := detectPlatforms(binaries, opts.Force, log)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	repo, err := createRepository(ref, opts.Insecure)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	tags := pushTags(repo.Reference.Reference, opts.Tags)
+	if len(tags) == 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("no tag to push %s as", ref)
+	}
+	// The index is merged with the one at the first tag
+	repo.Reference.Reference = tags[0]
+
+	// Setup authentication
+	if err := setupAuth(repo, opts.Username, opts.Password, log); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	log.Info("Pushing", "ref", ref, "binaries", len(binaries), "tags", tags)
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	opts.Progress = serializeProgress(opts.Progress)
+
+	// Manifests are built before anything is uploaded, so that the tags can
+	// be checked against the digest of the index
+	manifests := make([]*binaryManifest, len(binaries))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+
+	for i, binary := range binaries {
+		g.Go(func() error {
+			manifest, err := newBinaryManifest(binary, getMediaTypeForFormat(formats[i], binary.OS), binaryAnnotations(binary, tags, opts))
+			if err != nil {
+				return fmt.Errorf("failed to read binary %s: %w", platformString(binary.platform()), err)
+			}
+			manifests[i] = manifest
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	// The index lists the binaries in the order they were given
+	manifestDescriptors := make([]ocispec.Descriptor, len(manifests))
+	for i, manifest := range manifests {
+		manifestDescriptors[i] = manifest.desc
+	}
+
+	indexDesc, updates, err := planTags(ctx, repo, tags, manifestDescriptors, opts.Merge)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	if opts.DryRun {
+		for _, update := range updates {
+			log.Info("Would tag", "tag", update.Tag, "old", update.Old, "new", update.New)
+			if opts.OnTag != nil {
+				opts.OnTag(update)
+			}
+		}
+		if opts.Immutable {
+			return indexDesc, checkImmutable(updates)
+		}
+		return indexDesc, nil
+	}
+
+	if opts.Immutable {
+		if err := checkImmutable(updates); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+
+	g, gctx = errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+
+	for _, manifest := range manifests {
+		g.Go(func() error {
+			log.Debug("Pushing binary", "platform", platformString(manifest.binary.platform()), "path", manifest.binary.Path)
+			if err := pushBinary(gctx, repo, manifest, opts, log); err != nil {
+				return fmt.Errorf("failed to push binary %s: %w", platformString(manifest.binary.platform()), err)
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	if opts.Merge {
+		// Merging tags the first tag itself
+		indexDesc, err = mergeAndTagIndex(ctx, repo, manifestDescriptors, opts.Immutable, log)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		updates[0].New = indexDesc.Digest
+		if opts.OnTag != nil {
+			opts.OnTag(updates[0])
+		}
+		updates = updates[1:]
+	} else {
+		log.Info("Creating manifest index", "ref", ref)
+		indexDesc, err = createAndPushIndex(ctx, repo, manifestDescriptors)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to create manifest index: %w", err)
+		}
+	}
+
+	for _, update := range updates {
+		update.New = indexDesc.Digest
+		if err := tagIndex(ctx, repo, indexDesc, update, opts.Immutable); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		if opts.OnTag != nil {
+			opts.OnTag(update)
+		}
+	}
+
+	return indexDesc, nil
+}
+
+// pushTags returns the tag of the reference followed by the additional
+// tags, without duplicates. Digest references are not tags.
+func pushTags(reference string, extra []string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range append([]string{reference}, extra...) {
+		if tag == "" || seen[tag] || digest.Digest(tag).Validate() == nil {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// planTags computes the index that pushing manifests creates, merged with
+// the index at the first tag if merge is set, and resolves what each tag
+// currently points to. Nothing is uploaded.
+func planTags(ctx context.Context, repo *remote.Repository, tags []string, manifests []ocispec.Descriptor, merge bool) (ocispec.Descriptor, []TagUpdate, error) {
+	if merge {
+		_, existing, err := fetchIndexManifests(ctx, repo, tags[0])
+		if err != nil {
+			return ocispec.Descriptor{}, nil, err
+		}
+		manifests = mergeManifests(existing, manifests)
+	}
+	_, indexDesc := buildIndex(manifests)
+
+	updates := make([]TagUpdate, len(tags))
+	for i, tag := range tags {
+		old, err := resolveTagDigest(ctx, repo, tag)
+		if err != nil {
+			return ocispec.Descriptor{}, nil, err
+		}
+		updates[i] = TagUpdate{Tag: tag, Old: old, New: indexDesc.Digest}
+	}
+
+	return indexDesc, updates, nil
+}
+
+// checkImmutable fails with an *ImmutableTagError for the first update that
+// moves an existing tag
+func checkImmutable(updates []TagUpdate) error {
+	for _, update := range updates {
+		if update.Old != "" && update.Old != update.New {
+			return &ImmutableTagError{Tag: update.Tag, Old: update.Old, New: update.New}
+		}
+	}
+	return nil
+}
+
+// tagIndex tags indexDesc as update.Tag. If immutable is set, the tag is
+// checked again right before, in case it was pushed concurrently.
+func tagIndex(ctx context.Context, repo *remote.Repository, indexDesc ocispec.Descriptor, update TagUpdate, immutable bool) error {
+	if immutable {
+		current, err := resolveTagDigest(ctx, repo, update.Tag)
+		if err != nil {
+			return err
+		}
+		if current != "" && current != indexDesc.Digest {
+			return &ImmutableTagError{Tag: update.Tag, Old: current, New: indexDesc.Digest}
+		}
+	}
+
+	if err := repo.Tag(ctx, indexDesc, update.Tag); err != nil {
+		return fmt.Errorf("failed to tag %s: %w", update.Tag, err)
+	}
+	return nil
+}
+
+// detectPlatforms completes and checks the platform of every binary against
+// its headers. A mismatch is only logged if force is set. It returns the
+// binaries along with their detected formats.
+func detectPlatforms(binaries []PlatformBinary, force bool, log *slog.Logger) ([]PlatformBinary, []string, error) {
+	resolved := make([]PlatformBinary, 0, len(binaries))
+	formats := make([]string, 0, len(binaries))
+	seen := make(map[string]string)
+
+	for _, binary := range binaries {
+		detected, err := DetectPlatform(binary.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", binary.Path, err)
+		}
+
+		binary, err = resolvePlatform(binary, detected)
+		var mismatch *PlatformMismatchError
+		if errors.As(err, &mismatch) && force {
+			log.Warn("Pushing despite platform mismatch", "path", binary.Path, "error", err)
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		if detected.Format != "" {
+			log.Debug("Detected platform", "path", binary.Path, "format", detected.Format, "platform", platformString(binary.platform()))
+		}
+
+		platform := platformString(binary.platform())
+		if other, ok := seen[platform]; ok {
+			return nil, nil, fmt.Errorf("%s and %s are both %s", other, binary.Path, platform)
+		}
+		seen[platform] = binary.Path
+
+		resolved = append(resolved, binary)
+		formats = append(formats, detected.Format)
+	}
+
+	return resolved, formats, nil
+}
+
+// binaryManifest is the manifest of a binary, built before anything is
+// uploaded
+type binaryManifest struct {
+	binary     PlatformBinary
+	binaryDesc ocispec.Descriptor
+	data       []byte
+	desc       ocispec.Descriptor
+}
+
+// newBinaryManifest digests binary and builds its manifest. The manifest is
+// an artifact of type ArtifactType with the empty config, and is listed in
+// the index with its annotations, so that they survive merges and can be
+// shown without fetching every manifest.
+func newBinaryManifest(binary PlatformBinary, mediaType string, annotations map[string]string) (*binaryManifest, error) {
+	dgst, size, err := digestFile(binary.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	binaryDesc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    dgst,
+		Size:      size,
+		Annotations: map[string]string{
+			ocispec.AnnotationTitle: strings.ReplaceAll(platformString(binary.platform()), "/", "-"),
+		},
+	}
+
+	manifest := ocispec.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: ArtifactType,
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{binaryDesc},
+		Annotations:  annotations,
+	}
+
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	platform := binary.platform()
+	return &binaryManifest{
+		binary:     binary,
+		binaryDesc: binaryDesc,
+		data:       manifestBytes,
+		desc: ocispec.Descriptor{
+			MediaType:    ocispec.MediaTypeImageManifest,
+			ArtifactType: ArtifactType,
+			Digest:       digest.FromBytes(manifestBytes),
+			Size:         int64(len(manifestBytes)),
+			Annotations:  annotations,
+			Platform:     &platform,
+		},
+	}, nil
+}
+
+// binaryAnnotations returns the annotations of the manifest of binary. From
+// lowest to highest precedence, they come from opts.DefaultAnnotations, the
+// build information of Go binaries, the first tag that is a semantic
+// version, and opts.Annotations. It returns nil if there are none.
+func binaryAnnotations(binary PlatformBinary, tags []string, opts PushOptions) map[string]string {
+	annotations := make(map[string]string)
+	maps.Copy(annotations, opts.DefaultAnnotations)
+
+	// Binaries that were not built by Go have no build information
+	if info, err := buildinfo.ReadFile(binary.Path); err == nil {
+		maps.Copy(annotations, buildInfoAnnotations(newGoBuildInfo(info)))
+	}
+
+	for _, tag := range tags {
+		if _, ok := parseVersion(tag); ok {
+			annotations[ocispec.AnnotationVersion] = tag
+			break
+		}
+	}
+
+	maps.Copy(annotations, opts.Annotations)
+
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// buildInfoAnnotations returns the standard annotations that can be derived
+// from the build information of a Go binary
+func buildInfoAnnotations(info *GoBuildInfo) map[string]string {
+	annotations := make(map[string]string)
+	if info.RevisionTime != "" {
+		annotations[ocispec.AnnotationCreated] = info.RevisionTime
+	}
+	if info.Revision != "" {
+		annotations[ocispec.AnnotationRevision] = info.Revision
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		annotations[ocispec.AnnotationVersion] = v
+	}
+	if source := moduleSource(info.Main.Path); source != "" {
+		annotations[ocispec.AnnotationSource] = source
+	}
+	return annotations
+}
+
+// moduleSource returns the URL of the source of a Go module, which is its
+// path without the major version suffix. Module paths without a host, such
+// as those of local builds, have none.
+func moduleSource(modulePath string) string {
+	host, _, _ := strings.Cut(modulePath, "/")
+	if !strings.Contains(host, ".") {
+		return ""
+	}
+
+	if dir, last := path.Split(modulePath); len(last) > 1 && last[0] == 'v' {
+		if major, err := strconv.Atoi(last[1:]); err == nil && major >= 2 {
+			modulePath = strings.TrimSuffix(dir, "/")
+		}
+	}
+
+	return "https://" + modulePath
+}
+
+func pushBinary(ctx context.Context, repo *remote.Repository, manifest *binaryManifest, opts PushOptions, log *slog.Logger) error {
+	chunkSize := opts.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	progress := newProgressReporter(opts.Progress, platformString(manifest.binary.platform()), manifest.binaryDesc.Digest, manifest.binaryDesc.Size)
+	if err := pushBinaryManifest(ctx, repo, manifest, chunkSize, progress, log); err != nil {
+		progress.send(EventError, 0, err)
+		return err
+	}
+	return nil
+}
+
+// pushBinaryManifest uploads the binary of manifest along with the manifest
+func pushBinaryManifest(ctx context.Context, repo *remote.Repository, manifest *binaryManifest, chunkSize int64, progress *progressReporter, log *slog.Logger) error {
+	uploaded, err := pushBlobFile(ctx, repo, manifest.binary.Path, manifest.binaryDesc, chunkSize, progress)
+	if err != nil {
+		return err
+	}
+	if !uploaded {
+		log.Debug("Blob already exists, skipping upload", "platform", progress.platform, "digest", manifest.binaryDesc.Digest)
+	}
+
+	// The same empty config is used for all binaries
+	if err := pushBlobBytes(ctx, repo, ocispec.DescriptorEmptyJSON, ocispec.DescriptorEmptyJSON.Data); err != nil {
+		return err
+	}
+
+	if err := repo.Push(ctx, manifest.desc, bytes.NewReader(manifest.data)); err != nil {
+		return err
+	}
+
+	if uploaded {
+		progress.send(EventDone, manifest.binaryDesc.Size, nil)
+	}
+
+	return nil
+}
+
+func createAndPushIndex(ctx context.Context, repo *remote.Repository, manifests []ocispec.Descriptor) (ocispec.Descriptor, error) {
+	indexBytes, indexDesc := buildIndex(manifests)
+
+	if err := repo.Push(ctx, indexDesc, bytes.NewReader(indexBytes)); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return indexDesc, nil
+}
+
+// buildIndex returns the content and descriptor of an index over manifests
+func buildIndex(manifests []ocispec.Descriptor) ([]byte, ocispec.Descriptor) {
+	index := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: manifests,
+	}
+
+	// An index of descriptors always marshals
+	indexBytes, _ := json.Marshal(index)
+
+	return indexBytes, ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(indexBytes),
+		Size:      int64(len(indexBytes)),
+	}
+}
+
+// getMediaTypeForFormat returns the layer media type for a binary format
+// as detected by DetectPlatform. Formats DetectPlatform does not recognise
+// are told apart by the operating system of the binary.
+func getMediaTypeForFormat(format, goos string) string {
+	switch format {
+	case FormatWasm:
+		return "application/vnd.bolter.wasm.v1"
+	case FormatPE:
+		return "application/vnd.bolter.windows.exe.v1"
+	case FormatMachO:
+		return "application/vnd.bolter.macho.v1"
+	case FormatELF:
+		return "application/vnd.bolter.elf.v1"
+	}
+
+	switch goos {
+	case "plan9":
+		return "application/vnd.bolter.plan9.v1"
+	case "aix":
+		return "application/vnd.bolter.xcoff.v1"
+	default:
+		return "application/vnd.bolter.binary.v1"
+	}
+}
@@ -0,0 +1,234 @@
+package bolter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestPushTags(t *testing.T) {
+	_, host := newTestRegistry(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	binary := func(name, content string) []PlatformBinary {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return []PlatformBinary{{Path: path, OS: "linux", Architecture: "amd64"}}
+	}
+	v1 := binary("v1", "first build")
+	v2 := binary("v2", "second build")
+
+	var updates []TagUpdate
+	opts := PushOptions{
+		Insecure: true,
+		Tags:     []string{"v1.2", "latest", "v1.2"},
+		OnTag:    func(u TagUpdate) { updates = append(updates, u) },
+	}
+
+	first, err := Push(ctx, host+"/org/tool:v1.2.3", v1, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 3 {
+		t.Fatalf("got %d tag updates, want 3: %+v", len(updates), updates)
+	}
+	for i, tag := range []string{"v1.2.3", "v1.2", "latest"} {
+		if updates[i].Tag != tag || updates[i].Old != "" || updates[i].New != first.Digest {
+			t.Errorf("update %d = %+v, want %s -> %s", i, updates[i], tag, first.Digest)
+		}
+	}
+
+	// Pushing the same content again does not move immutable tags
+	opts.Immutable = true
+	if _, err := Push(ctx, host+"/org/tool:v1.2.3", v1, opts); err != nil {
+		t.Fatalf("repushing identical content: %v", err)
+	}
+
+	// A dry run reports the change without uploading anything
+	updates = nil
+	opts.Immutable = false
+	opts.DryRun = true
+	planned, err := Push(ctx, host+"/org/tool:v1.2.3", v2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 3 || updates[0].Old != first.Digest || updates[0].New != planned.Digest {
+		t.Errorf("dry run updates = %+v", updates)
+	}
+	if current, _ := Resolve(ctx, host+"/org/tool:v1.2.3", ResolveOptions{Insecure: true}); current.Digest != first.Digest.String() {
+		t.Errorf("dry run moved the tag to %s", current.Digest)
+	}
+
+	opts.DryRun = false
+	opts.Immutable = true
+	_, err = Push(ctx, host+"/org/tool:v1.2.3", v2, opts)
+	var immutable *ImmutableTagError
+	if !errors.As(err, &immutable) || !errors.Is(err, ErrImmutableTag) {
+		t.Fatalf("expected ImmutableTagError, got %v", err)
+	}
+	if immutable.Old != first.Digest || immutable.New != planned.Digest {
+		t.Errorf("error = %+v, want %s -> %s", immutable, first.Digest, planned.Digest)
+	}
+
+	opts.Immutable = false
+	second, err := Push(ctx, host+"/org/tool:v1.2.3", v2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Digest != planned.Digest {
+		t.Errorf("pushed %s, dry run planned %s", second.Digest, planned.Digest)
+	}
+}
+
+func TestPushAnnotations(t *testing.T) {
+	_, host := newTestRegistry(t)
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(path, []byte("not a Go binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Push(ctx, host+"/org/tool:latest", []PlatformBinary{{Path: path, OS: "linux", Architecture: "arm", Variant: "v7"}}, PushOptions{
+		Insecure: true,
+		Tags:     []string{"v1.2.3"},
+		Annotations: map[string]string{
+			ocispec.AnnotationDescription: "A tool",
+		},
+		DefaultAnnotations: map[string]string{
+			ocispec.AnnotationRevision:    "abc123",
+			ocispec.AnnotationDescription: "overridden",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inspection, err := Inspect(ctx, host+"/org/tool:v1.2.3", InspectOptions{Insecure: true, Platform: "linux/arm/v7", CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		ocispec.AnnotationVersion:     "v1.2.3",
+		ocispec.AnnotationRevision:    "abc123",
+		ocispec.AnnotationDescription: "A tool",
+	}
+	if len(inspection.Annotations) != len(want) {
+		t.Errorf("annotations = %v, want %v", inspection.Annotations, want)
+	}
+	for key, value := range want {
+		if inspection.Annotations[key] != value {
+			t.Errorf("annotation %s = %q, want %q", key, inspection.Annotations[key], value)
+		}
+	}
+	if inspection.ArtifactType != ArtifactType || inspection.Config.MediaType != ocispec.MediaTypeEmptyJSON {
+		t.Errorf("artifact type %q with config %q", inspection.ArtifactType, inspection.Config.MediaType)
+	}
+	if title := inspection.Layer.Annotations[ocispec.AnnotationTitle]; title != "linux-arm-v7" {
+		t.Errorf("layer title = %q, want linux-arm-v7", title)
+	}
+
+	// The index lists each manifest with its annotations
+	var index ocispec.Index
+	if err := json.Unmarshal(inspection.Index, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Annotations[ocispec.AnnotationVersion] != "v1.2.3" {
+		t.Errorf("index = %s", inspection.Index)
+	}
+}
+
+func TestModuleSource(t *testing.T) {
+	tests := map[string]string{
+		"github.com/aep/bolter":    "https://github.com/aep/bolter",
+		"github.com/org/tool/v2":   "https://github.com/org/tool",
+		"github.com/org/tool/v1":   "https://github.com/org/tool/v1",
+		"golang.org/x/tools/gopls": "https://golang.org/x/tools/gopls",
+		"example/local":            "",
+		"command-line-arguments":   "",
+	}
+
+	for path, want := range tests {
+		if got := moduleSource(path); got != want {
+			t.Errorf("moduleSource(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestPushMergeImmutable(t *testing.T) {
+	reg, host := newTestRegistry(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	binary := func(content, arch string) []PlatformBinary {
+		path := filepath.Join(dir, arch)
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return []PlatformBinary{{Path: path, OS: "linux", Architecture: arch}}
+	}
+	amd64 := binary("amd64 build", "amd64")
+	arm64 := binary("arm64 build", "arm64")
+
+	opts := PushOptions{Insecure: true, Merge: true, Immutable: true}
+
+	// Merging into a tag that does not exist yet creates it
+	first, err := Push(ctx, host+"/org/tool:v1", amd64, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Merging another platform would move the tag
+	_, err = Push(ctx, host+"/org/tool:v1", arm64, opts)
+	if !errors.Is(err, ErrImmutableTag) {
+		t.Fatalf("expected ErrImmutableTag, got %v", err)
+	}
+	if got := reg.tag("org/tool", "v1"); got != first.Digest.String() {
+		t.Errorf("v1 was moved to %s", got)
+	}
+
+	// A tag created concurrently while merging is not overwritten either
+	created := false
+	reg.onRequest = func(req *http.Request) {
+		if !created && req.Method == http.MethodPut && req.Header.Get("Content-Type") == ocispec.MediaTypeImageIndex {
+			created = true
+			reg.tags["org/tool"]["v2"] = first.Digest.String()
+		}
+	}
+	_, err = Push(ctx, host+"/org/tool:v2", arm64, opts)
+	var immutable *ImmutableTagError
+	if !errors.As(err, &immutable) || immutable.Tag != "v2" || immutable.Old != first.Digest {
+		t.Fatalf("expected ImmutableTagError for v2, got %v", err)
+	}
+	if got := reg.tag("org/tool", "v2"); got != first.Digest.String() {
+		t.Errorf("v2 was moved to %s", got)
+	}
+}
+
+func TestGetMediaTypeForFormat(t *testing.T) {
+	tests := []struct {
+		format, goos, want string
+	}{
+		{FormatELF, "linux", "application/vnd.bolter.elf.v1"},
+		{FormatMachO, "ios", "application/vnd.bolter.macho.v1"},
+		{"", "plan9", "application/vnd.bolter.plan9.v1"},
+		{"", "aix", "application/vnd.bolter.xcoff.v1"},
+		{"", "linux", "application/vnd.bolter.binary.v1"},
+	}
+
+	for _, tt := range tests {
+		if got := getMediaTypeForFormat(tt.format, tt.goos); got != tt.want {
+			t.Errorf("getMediaTypeForFormat(%q, %q) = %q, want %q", tt.format, tt.goos, got, tt.want)
+		}
+	}
+}
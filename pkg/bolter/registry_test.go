@@ -0,0 +1,182 @@
+package bolter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// testRegistry is a minimal in-memory OCI distribution registry
+type testRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte            // repository + "@" + digest
+	manifests map[string]testManifest      // repository + "@" + digest
+	tags      map[string]map[string]string // repository -> tag -> digest
+	uploads   map[string][]byte
+	nextID    int
+	mounts    int
+	server    *httptest.Server
+	// onRequest is called with the registry locked before each request is
+	// served, to simulate concurrent changes
+	onRequest func(req *http.Request)
+}
+
+type testManifest struct {
+	mediaType string
+	data      []byte
+}
+
+// newTestRegistry starts a registry and returns it along with its host
+func newTestRegistry(t *testing.T) (*testRegistry, string) {
+	t.Helper()
+	r := &testRegistry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string]testManifest),
+		tags:      make(map[string]map[string]string),
+		uploads:   make(map[string][]byte),
+	}
+	r.server = httptest.NewServer(r)
+	t.Cleanup(r.server.Close)
+	return r, strings.TrimPrefix(r.server.URL, "http://")
+}
+
+// close shuts the registry down, so that it can no longer be reached
+func (r *testRegistry) close() {
+	r.server.Close()
+}
+
+// tag returns the digest repository:tag points to
+func (r *testRegistry) tag(repository, tag string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tags[repository][tag]
+}
+
+// mountCount returns how many blobs were mounted from other repositories
+func (r *testRegistry) mountCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mounts
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.onRequest != nil {
+		r.onRequest(req)
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case strings.HasSuffix(path, "/tags/list"):
+		repository := strings.TrimSuffix(path, "/tags/list")
+		var tags []string
+		for tag := range r.tags[repository] {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name":%q,"tags":["%s"]}`, repository, strings.Join(tags, `","`))
+	case strings.Contains(path, "/blobs/uploads/"):
+		r.serveUpload(w, req, path)
+	case strings.Contains(path, "/blobs/"):
+		repository, dgst, _ := strings.Cut(path, "/blobs/")
+		data, ok := r.blobs[repository+"@"+dgst]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Header().Set("Docker-Content-Digest", dgst)
+		if req.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case strings.Contains(path, "/manifests/"):
+		repository, reference, _ := strings.Cut(path, "/manifests/")
+		r.serveManifest(w, req, repository, reference)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (r *testRegistry) serveUpload(w http.ResponseWriter, req *http.Request, path string) {
+	repository, id, _ := strings.Cut(path, "/blobs/uploads/")
+
+	switch req.Method {
+	case http.MethodPost:
+		if mount := req.URL.Query().Get("mount"); mount != "" {
+			if data, ok := r.blobs[req.URL.Query().Get("from")+"@"+mount]; ok {
+				r.blobs[repository+"@"+mount] = data
+				r.mounts++
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		r.nextID++
+		id = fmt.Sprint(r.nextID)
+		r.uploads[id] = nil
+		w.Header().Set("Location", "/v2/"+repository+"/blobs/uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPatch:
+		data, _ := io.ReadAll(req.Body)
+		r.uploads[id] = append(r.uploads[id], data...)
+		w.Header().Set("Location", "/v2/"+repository+"/blobs/uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		data, _ := io.ReadAll(req.Body)
+		data = append(r.uploads[id], data...)
+		delete(r.uploads, id)
+		dgst := req.URL.Query().Get("digest")
+		if digest.FromBytes(data).String() != dgst {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+		r.blobs[repository+"@"+dgst] = data
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func (r *testRegistry) serveManifest(w http.ResponseWriter, req *http.Request, repository, reference string) {
+	switch req.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(req.Body)
+		dgst := digest.FromBytes(data).String()
+		r.manifests[repository+"@"+dgst] = testManifest{mediaType: req.Header.Get("Content-Type"), data: data}
+		if digest.Digest(reference).Validate() != nil {
+			if r.tags[repository] == nil {
+				r.tags[repository] = make(map[string]string)
+			}
+			r.tags[repository][reference] = dgst
+		}
+		w.Header().Set("Docker-Content-Digest", dgst)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet, http.MethodHead:
+		dgst := reference
+		if tagged, ok := r.tags[repository][reference]; ok {
+			dgst = tagged
+		}
+		m, ok := r.manifests[repository+"@"+dgst]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(m.data)))
+		w.Header().Set("Docker-Content-Digest", dgst)
+		if req.Method == http.MethodGet {
+			w.Write(m.data)
+		}
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,203 @@
+package bolter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// ResolveOptions configures the Resolve operation
+type ResolveOptions struct {
+	// Username for registry authentication
+	Username string
+	// Password for registry authentication
+	Password string
+	// Insecure allows insecure registry connections
+	Insecure bool
+	// Logger receives status messages. Nothing is logged if it is nil.
+	Logger *slog.Logger
+	// Prerelease allows version ranges to resolve to pre-release tags
+	Prerelease bool
+}
+
+// ResolvedReference is the result of resolving a reference
+type ResolvedReference struct {
+	// Reference is the concrete reference (e.g., "ghcr.io/org/tool:v1.4.2")
+	Reference string
+	// Tag the reference resolved to. Empty for digest references.
+	Tag string
+	// Digest of the index or manifest the reference points to
+	Digest string
+	// MediaType of the index or manifest
+	MediaType string
+	// Range is the requested version range, if any
+	Range string
+}
+
+// Resolve resolves ref to a digest. Besides tags and digests, ref may name
+// a version range after an "@", such as "ghcr.io/org/tool@^1.4",
+// "tool@~1.4.0" or "tool@latest-stable". Ranges are resolved by listing the
+// tags of the repository and picking the highest matching semantic version.
+func Resolve(ctx context.Context, ref string, opts ResolveOptions) (_ *ResolvedReference, err error) {
+	defer func() { err = classifyError(err) }()
+
+	_, _, resolved, err := resolveReference(ctx, ref, opts)
+	return resolved, err
+}
+
+// resolveReference resolves ref like Resolve and also returns the
+// authenticated repository and the descriptor ref points to
+func resolveReference(ctx context.Context, ref string, opts ResolveOptions) (*remote.Repository, ocispec.Descriptor, *ResolvedReference, error) {
+	base, rng, isRange := splitVersionRange(ref)
+	if isRange {
+		ref = base
+	}
+
+	repo, err := createRepository(ref, opts.Insecure)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, nil, fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	// Setup authentication
+	log := logger(opts.Logger)
+	if err := setupAuth(repo, opts.Username, opts.Password, log); err != nil {
+		return nil, ocispec.Descriptor{}, nil, err
+	}
+
+	resolved := &ResolvedReference{}
+
+	if isRange {
+		tag, err := resolveVersionRange(ctx, repo, rng, opts.Prerelease)
+		if err != nil {
+			return nil, ocispec.Descriptor{}, nil, err
+		}
+		repo.Reference.Reference = tag
+		resolved.Range = rng
+	}
+
+	descriptor, err := repo.Resolve(ctx, repo.Reference.Reference)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, nil, fmt.Errorf("failed to resolve reference: %w", err)
+	}
+
+	if isRange {
+		log.Info("Resolved version range", "range", rng, "tag", repo.Reference.Reference, "digest", descriptor.Digest)
+	}
+
+	resolved.Reference = repo.Reference.String()
+	resolved.Digest = descriptor.Digest.String()
+	resolved.MediaType = descriptor.MediaType
+	if !isDigestReference(repo.Reference) {
+		resolved.Tag = repo.Reference.Reference
+	}
+
+	return repo, descriptor, resolved, nil
+}
+
+// splitVersionRange splits "repo@range" into the repository and the range.
+// It reports false for references without a range, including digest
+// references like "repo@sha256:...".
+func splitVersionRange(ref string) (string, string, bool) {
+	at := strings.LastIndex(ref, "@")
+	if at < 0 || strings.Contains(ref[at+1:], "/") {
+		return ref, "", false
+	}
+
+	if digest.Digest(ref[at+1:]).Validate() == nil {
+		return ref, "", false
+	}
+
+	return ref[:at], ref[at+1:], true
+}
+
+// resolveVersionRange lists the tags of repo and returns the highest one
+// matching rng
+func resolveVersionRange(ctx context.Context, repo *remote.Repository, rng string, prerelease bool) (string, error) {
+	versionRange, err := parseVersionRange(rng)
+	if err != nil {
+		return "", err
+	}
+
+	var tags []string
+	err = repo.Tags(ctx, "", func(page []string) error {
+		tags = append(tags, page...)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	tag, ok := versionRange.highestMatchingTag(tags, prerelease)
+	if !ok {
+		return "", fmt.Errorf("no tag of %s matches %s", repo.Reference.Repository, rng)
+	}
+
+	return tag, nil
+}
+
+// resolveCachedVersionRange resolves rng like resolveVersionRange, but
+// serves the result from the cache until it expires and falls back to the
+// cached tag if the registry is unreachable
+func resolveCachedVersionRange(ctx context.Context, repo *remote.Repository, cacheDir, rng string, opts RunOptions) (string, error) {
+	// Ranges may contain characters that are not valid in file names
+	key := sha256.Sum256([]byte(rng + "\x00" + strconv.FormatBool(opts.Prerelease)))
+	rangeRef := repo.Reference
+	rangeRef.Reference = "range-" + hex.EncodeToString(key[:8])
+
+	var cached *cacheRef
+	if !opts.NoCache {
+		cached, _ = loadCacheRef(cacheDir, rangeRef)
+		if cached != nil && cached.Tag != "" {
+			// An explicit TTL overrides the one the range was saved with
+			expiresAt := cached.ExpiresAt
+			if opts.ResolveTTL != 0 {
+				expiresAt = cached.ResolvedAt.Add(opts.ResolveTTL)
+			}
+			if time.Now().Before(expiresAt) {
+				return cached.Tag, nil
+			}
+		}
+	}
+
+	tag, err := resolveVersionRange(ctx, repo, rng, opts.Prerelease)
+	if err != nil {
+		if cached != nil && cached.Tag != "" && !errors.Is(err, errdef.ErrNotFound) {
+			logger(opts.Logger).Warn("Failed to resolve version range, using cached tag", "range", rng, "tag", cached.Tag, "error", err)
+			return cached.Tag, nil
+		}
+		return "", err
+	}
+
+	if opts.NoCache {
+		return tag, nil
+	}
+
+	ttl := opts.ResolveTTL
+	if ttl <= 0 {
+		ttl = DefaultResolveTTL
+	}
+
+	now := time.Now()
+	cr := cacheRef{
+		Reference:  repo.Reference.Registry + "/" + repo.Reference.Repository + "@" + rng,
+		Tag:        tag,
+		ResolvedAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	if err := writeCacheRef(cacheDir, rangeRef, cr); err != nil {
+		logger(opts.Logger).Warn("Failed to save cache ref", "range", rng, "error", err)
+	}
+
+	return tag, nil
+}
@@ -0,0 +1,334 @@
+package bolter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a semantic version parsed from a tag
+type version struct {
+	major, minor, patch uint64
+	prerelease          []string
+}
+
+// parseVersion parses a tag such as "v1.4.2", "1.4" or "2.0.0-rc.1".
+// Missing minor and patch numbers are taken as zero; build metadata is ignored.
+func parseVersion(s string) (version, bool) {
+	s = strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(s, "+")
+
+	core, pre, hasPre := strings.Cut(s, "-")
+	if hasPre && pre == "" {
+		return version{}, false
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return version{}, false
+	}
+
+	var numbers [3]uint64
+	for i, part := range parts {
+		n, ok := parseNumber(part)
+		if !ok {
+			return version{}, false
+		}
+		numbers[i] = n
+	}
+
+	v := version{major: numbers[0], minor: numbers[1], patch: numbers[2]}
+	if hasPre {
+		v.prerelease = strings.Split(pre, ".")
+		for _, id := range v.prerelease {
+			if id == "" {
+				return version{}, false
+			}
+		}
+	}
+
+	return v, true
+}
+
+func parseNumber(s string) (uint64, bool) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	return n, err == nil
+}
+
+func (v version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if len(v.prerelease) > 0 {
+		s += "-" + strings.Join(v.prerelease, ".")
+	}
+	return s
+}
+
+func (v version) isPrerelease() bool {
+	return len(v.prerelease) > 0
+}
+
+// lowest returns the lowest possible pre-release of v, so that an exclusive
+// upper bound of 2.0.0 also excludes 2.0.0-rc.1
+func (v version) lowest() version {
+	v.prerelease = []string{"0"}
+	return v
+}
+
+// compare returns -1, 0 or 1 following semver precedence rules
+func (v version) compare(o version) int {
+	for _, pair := range [][2]uint64{{v.major, o.major}, {v.minor, o.minor}, {v.patch, o.patch}} {
+		if pair[0] < pair[1] {
+			return -1
+		}
+		if pair[0] > pair[1] {
+			return 1
+		}
+	}
+
+	// A pre-release has lower precedence than the release
+	switch {
+	case !v.isPrerelease() && !o.isPrerelease():
+		return 0
+	case !v.isPrerelease():
+		return 1
+	case !o.isPrerelease():
+		return -1
+	}
+
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		a, b := v.prerelease[i], o.prerelease[i]
+		an, aNumeric := parseNumber(a)
+		bn, bNumeric := parseNumber(b)
+
+		switch {
+		case aNumeric && bNumeric:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aNumeric:
+			return -1
+		case bNumeric:
+			return 1
+		default:
+			if c := strings.Compare(a, b); c != 0 {
+				return c
+			}
+		}
+	}
+
+	switch {
+	case len(v.prerelease) < len(o.prerelease):
+		return -1
+	case len(v.prerelease) > len(o.prerelease):
+		return 1
+	}
+	return 0
+}
+
+type comparator struct {
+	op string
+	v  version
+}
+
+func (c comparator) matches(v version) bool {
+	cmp := v.compare(c.v)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// versionRange is a set of alternatives, each of which is a set of
+// comparators that must all match
+type versionRange struct {
+	alternatives [][]comparator
+}
+
+// parseVersionRange parses a range such as "^1.4", "~1.4.0", ">=1.2 <2",
+// "1.x", "^1.0 || ^2.0" or "latest-stable". Pre-releases only match if the
+// range names a pre-release of the same major.minor.patch.
+func parseVersionRange(s string) (*versionRange, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty version range")
+	}
+
+	r := &versionRange{}
+
+	if s == "latest-stable" || s == "*" || s == "x" {
+		r.alternatives = [][]comparator{{}}
+		return r, nil
+	}
+
+	for _, alternative := range strings.Split(s, "||") {
+		terms := strings.Fields(strings.ReplaceAll(alternative, ",", " "))
+		if len(terms) == 0 {
+			return nil, fmt.Errorf("invalid version range %q", s)
+		}
+
+		var comparators []comparator
+		for _, term := range terms {
+			cs, err := parseRangeTerm(term)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version range %q: %w", s, err)
+			}
+			comparators = append(comparators, cs...)
+		}
+		r.alternatives = append(r.alternatives, comparators)
+	}
+
+	return r, nil
+}
+
+// parseRangeTerm expands a single term of a range into comparators
+func parseRangeTerm(term string) ([]comparator, error) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if rest, ok := strings.CutPrefix(term, op); ok {
+			v, _, err := parsePartialVersion(rest)
+			if err != nil {
+				return nil, err
+			}
+			return []comparator{{op: op, v: v}}, nil
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(term, "^"):
+		v, parts, err := parsePartialVersion(term[1:])
+		if err != nil {
+			return nil, err
+		}
+		// Allow changes that do not modify the left-most non-zero part
+		upper := version{major: v.major + 1}
+		if v.major == 0 && parts > 1 {
+			upper = version{minor: v.minor + 1}
+			if v.minor == 0 && parts > 2 {
+				upper = version{patch: v.patch + 1}
+			}
+		}
+		return []comparator{{">=", v}, {"<", upper.lowest()}}, nil
+
+	case strings.HasPrefix(term, "~"):
+		v, parts, err := parsePartialVersion(term[1:])
+		if err != nil {
+			return nil, err
+		}
+		// Allow patch-level changes, or minor-level if only a major is given
+		upper := version{major: v.major, minor: v.minor + 1}
+		if parts == 1 {
+			upper = version{major: v.major + 1}
+		}
+		return []comparator{{">=", v}, {"<", upper.lowest()}}, nil
+
+	default:
+		v, parts, err := parsePartialVersion(term)
+		if err != nil {
+			return nil, err
+		}
+		switch parts {
+		case 0:
+			return nil, nil
+		case 1:
+			return []comparator{{">=", v}, {"<", version{major: v.major + 1}.lowest()}}, nil
+		case 2:
+			return []comparator{{">=", v}, {"<", version{major: v.major, minor: v.minor + 1}.lowest()}}, nil
+		default:
+			return []comparator{{"=", v}}, nil
+		}
+	}
+}
+
+// parsePartialVersion parses versions like "1", "1.4", "1.4.x" or "1.4.2-rc.1"
+// and returns how many numeric parts were given before any wildcard
+func parsePartialVersion(s string) (version, int, error) {
+	s = strings.TrimPrefix(s, "v")
+	core, pre, hasPre := strings.Cut(s, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return version{}, 0, fmt.Errorf("invalid version %q", s)
+	}
+
+	var numbers [3]uint64
+	given := 0
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, ok := parseNumber(part)
+		if !ok {
+			return version{}, 0, fmt.Errorf("invalid version %q", s)
+		}
+		numbers[i] = n
+		given++
+	}
+
+	v := version{major: numbers[0], minor: numbers[1], patch: numbers[2]}
+	if hasPre {
+		if given < 3 {
+			return version{}, 0, fmt.Errorf("invalid version %q", s)
+		}
+		v.prerelease = strings.Split(pre, ".")
+	}
+
+	return v, given, nil
+}
+
+// matches reports whether v satisfies the range. Unless prerelease is set,
+// a pre-release only satisfies an alternative with a comparator naming a
+// pre-release of the same major.minor.patch, so that ">=1.4.0-rc.1" allows
+// 1.4.0-rc.2 but not 1.5.0-rc.1.
+func (r *versionRange) matches(v version, prerelease bool) bool {
+	for _, alternative := range r.alternatives {
+		matched := true
+		allowed := !v.isPrerelease() || prerelease
+		for _, c := range alternative {
+			if !c.matches(v) {
+				matched = false
+				break
+			}
+			if c.v.isPrerelease() && c.v.major == v.major && c.v.minor == v.minor && c.v.patch == v.patch {
+				allowed = true
+			}
+		}
+		if matched && allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// highestMatchingTag returns the tag with the highest version that
+// satisfies the range
+func (r *versionRange) highestMatchingTag(tags []string, prerelease bool) (string, bool) {
+	var best string
+	var bestVersion version
+	found := false
+
+	for _, tag := range tags {
+		v, ok := parseVersion(tag)
+		if !ok || !r.matches(v, prerelease) {
+			continue
+		}
+		if !found || v.compare(bestVersion) > 0 {
+			best, bestVersion, found = tag, v, true
+		}
+	}
+
+	return best, found
+}
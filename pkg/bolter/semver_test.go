@@ -0,0 +1,53 @@
+package bolter
+
+import "testing"
+
+func TestVersionRange(t *testing.T) {
+	tags := []string{"v1.3.9", "v1.4.0", "v1.4.2", "v1.5.0", "v1.6.0-rc.1", "v2.0.0-beta.2", "2.0.0", "latest", "v0.4.1", "v0.4.7", "v0.5.0"}
+
+	tests := []struct {
+		rng        string
+		prerelease bool
+		want       string
+	}{
+		{"^1.4", false, "v1.5.0"},
+		{"^1.4", true, "v1.6.0-rc.1"},
+		{"~1.4.0", false, "v1.4.2"},
+		{"~1", false, "v1.5.0"},
+		{"1.4.x", false, "v1.4.2"},
+		{"1.4.0", false, "v1.4.0"},
+		{"^0.4", false, "v0.4.7"},
+		{">=1.4 <1.5", false, "v1.4.2"},
+		{"^1.0 || ^0.4", false, "v1.5.0"},
+		{"latest-stable", false, "2.0.0"},
+		{"^2.0.0-beta.1", false, "2.0.0"},
+		{"<2.0.0-beta.3, >=2.0.0-beta.1", false, "v2.0.0-beta.2"},
+		{"^1.5.0-rc.1", false, "v1.5.0"},
+		{"~1.6.0-rc.0", false, "v1.6.0-rc.1"},
+		{">=1.5.0-rc.1 <1.6.0 || >=2.0.0-beta.1 <2.0.0", false, "v2.0.0-beta.2"},
+		{"^1.4 || =2.0.0-beta.1", false, "v1.5.0"},
+		{"^3", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rng, func(t *testing.T) {
+			r, err := parseVersionRange(tt.rng)
+			if err != nil {
+				t.Fatalf("failed to parse range: %v", err)
+			}
+
+			got, _ := r.highestMatchingTag(tags, tt.prerelease)
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVersionRangeInvalid(t *testing.T) {
+	for _, rng := range []string{"", "^", "~1.a", "1.2.3.4", ">=", "1 ||"} {
+		if _, err := parseVersionRange(rng); err == nil {
+			t.Errorf("expected %q to be rejected", rng)
+		}
+	}
+}
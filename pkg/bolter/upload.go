@@ -0,0 +1,171 @@
+package bolter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// DefaultChunkSize is the size of the chunks binaries are uploaded in
+const DefaultChunkSize = 16 << 20
+
+// digestFile computes the digest and size of a file in one streaming pass
+func digestFile(path string) (digest.Digest, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(digester.Hash(), f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return digester.Digest(), size, nil
+}
+
+// pushBlobFile uploads the file at path as the blob desc straight from disk,
+// unless the registry already has it. Positive chunk sizes upload in chunks
+// of that size, others in a single streamed request. It reports whether the
+// blob was uploaded.
+func pushBlobFile(ctx context.Context, repo *remote.Repository, path string, desc ocispec.Descriptor, chunkSize int64, progress *progressReporter) (bool, error) {
+	if exists, err := repo.Blobs().Exists(ctx, desc); err == nil && exists {
+		progress.send(EventSkip, desc.Size, nil)
+		return false, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	progress.send(EventStart, 0, nil)
+
+	if chunkSize <= 0 {
+		if err := repo.Blobs().Push(ctx, desc, progress.reader(f, 0)); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if err := uploadChunked(ctx, repo, desc, f, chunkSize, progress); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// pushBlobBytes uploads small content such as configs, unless the registry
+// already has it
+func pushBlobBytes(ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor, data []byte) error {
+	if exists, err := repo.Blobs().Exists(ctx, desc); err == nil && exists {
+		return nil
+	}
+	return repo.Blobs().Push(ctx, desc, bytes.NewReader(data))
+}
+
+// uploadChunked uploads a blob with the chunked upload protocol of the OCI
+// distribution spec: POST to start a session, one PATCH per chunk and a
+// closing PUT with the digest. Chunks are streamed from f, so memory use
+// does not depend on the blob size.
+func uploadChunked(ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor, f *os.File, chunkSize int64, progress *progressReporter) error {
+	client := repo.Client
+	if client == nil {
+		client = auth.DefaultClient
+	}
+
+	scheme := "https"
+	if repo.PlainHTTP {
+		scheme = "http"
+	}
+	ref := repo.Reference
+	start := &url.URL{Scheme: scheme, Host: ref.Host(), Path: "/v2/" + ref.Repository + "/blobs/uploads/"}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, start.String(), nil)
+	if err != nil {
+		return err
+	}
+	location, err := doUploadRequest(client, req, false)
+	if err != nil {
+		return err
+	}
+
+	for offset := int64(0); offset < desc.Size; offset += chunkSize {
+		n := min(chunkSize, desc.Size-offset)
+
+		chunk := io.NewSectionReader(f, offset, n)
+		body := &progressReader{r: chunk, p: progress, current: offset}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPatch, location.String(), body)
+		if err != nil {
+			return err
+		}
+		// Allow the request to be replayed after an authentication challenge.
+		// The replay reuses body, so its bytes are not reported twice.
+		req.GetBody = func() (io.ReadCloser, error) {
+			if _, err := chunk.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			body.rewind(offset)
+			return io.NopCloser(body), nil
+		}
+		req.ContentLength = n
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+n-1))
+
+		if location, err = doUploadRequest(client, req, false); err != nil {
+			return err
+		}
+	}
+
+	q := location.Query()
+	q.Set("digest", desc.Digest.String())
+	location.RawQuery = q.Encode()
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPut, location.String(), nil)
+	if err != nil {
+		return err
+	}
+	_, err = doUploadRequest(client, req, true)
+	return err
+}
+
+// doUploadRequest sends req and returns the upload location of the response,
+// resolved against the request URL. Only the closing request of an upload
+// may omit the location.
+func doUploadRequest(client remote.Client, req *http.Request, last bool) (*url.URL, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// The spec asks for 202 Accepted, but some registries answer 204
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, parseErrorResponse(resp)
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		if last {
+			return req.URL, nil
+		}
+		return nil, fmt.Errorf("%s %q: missing Location header", req.Method, req.URL)
+	}
+
+	u, err := req.URL.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: invalid Location header %q: %w", req.Method, req.URL, location, err)
+	}
+	return u, nil
+}
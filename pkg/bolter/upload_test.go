@@ -0,0 +1,230 @@
+package bolter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+func TestUploadChunked(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	path := filepath.Join(t.TempDir(), "blob")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dgst, size, err := digestFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dgst != digest.FromBytes(data) || size != int64(len(data)) {
+		t.Fatalf("digestFile = %s, %d", dgst, size)
+	}
+
+	var mu sync.Mutex
+	var received bytes.Buffer
+	var ranges []string
+	var committed digest.Digest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/blobs/uploads/"):
+			w.Header().Set("Location", "/v2/test/blobs/uploads/session?state=0")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPatch:
+			ranges = append(ranges, r.Header.Get("Content-Range"))
+			io.Copy(&received, r.Body)
+			w.Header().Set("Location", fmt.Sprintf("/v2/test/blobs/uploads/session?state=%d", received.Len()))
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut:
+			committed = digest.Digest(r.URL.Query().Get("digest"))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	repo, err := createRepository(strings.TrimPrefix(server.URL, "http://")+"/test:latest", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desc := ocispec.Descriptor{MediaType: "application/octet-stream", Digest: dgst, Size: size}
+	var events []Event
+	progress := newProgressReporter(func(e Event) { events = append(events, e) }, "linux/amd64", dgst, size)
+	uploaded, err := pushBlobFile(context.Background(), repo, path, desc, 4096, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uploaded {
+		t.Error("blob was not uploaded")
+	}
+
+	if !bytes.Equal(received.Bytes(), data) {
+		t.Errorf("registry received %d bytes, want %d", received.Len(), len(data))
+	}
+	if want := []string{"0-4095", "4096-8191", "8192-9999"}; strings.Join(ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("chunks = %v, want %v", ranges, want)
+	}
+	if committed != dgst {
+		t.Errorf("committed digest = %s, want %s", committed, dgst)
+	}
+
+	if len(events) < 2 || events[0].Type != EventStart {
+		t.Fatalf("events = %+v, want a start event followed by progress", events)
+	}
+	if last := events[len(events)-1]; last.Type != EventProgress || last.Current != size || last.Total != size {
+		t.Errorf("last event = %+v, want progress %d/%d", last, size, size)
+	}
+}
+
+func TestUploadChunkedUnauthorized(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blob")
+	if err := os.WriteFile(path, []byte("binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dgst, size, err := digestFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED","message":"authentication required"}]}`))
+	}))
+	defer server.Close()
+
+	repo, err := createRepository(strings.TrimPrefix(server.URL, "http://")+"/test:latest", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desc := ocispec.Descriptor{MediaType: "application/octet-stream", Digest: dgst, Size: size}
+	_, err = pushBlobFile(context.Background(), repo, path, desc, 4096, nil)
+	var resp *errcode.ErrorResponse
+	if !errors.As(err, &resp) || resp.StatusCode != http.StatusUnauthorized || resp.Method != http.MethodPost {
+		t.Fatalf("expected an error response with status 401, got %v", err)
+	}
+	if !errors.Is(classifyError(err), ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", classifyError(err))
+	}
+}
+
+// replayClient is a registry that challenges the first chunk of an upload,
+// like an auth.Client sees it, and replays it with GetBody. Chunks are read
+// slowly so that every read is reported as progress.
+type replayClient struct {
+	received bytes.Buffer
+	replayed bool
+}
+
+func (c *replayClient) Do(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{StatusCode: http.StatusAccepted, Header: make(http.Header), Body: http.NoBody, Request: req}
+	switch req.Method {
+	case http.MethodHead:
+		resp.StatusCode = http.StatusNotFound
+	case http.MethodPatch:
+		body := req.Body
+		if !c.replayed {
+			c.replayed = true
+			if err := c.readSlowly(io.Discard, body); err != nil {
+				return nil, err
+			}
+			var err error
+			if body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.readSlowly(&c.received, body); err != nil {
+			return nil, err
+		}
+		resp.Header.Set("Location", "/v2/test/blobs/uploads/session")
+	case http.MethodPost:
+		resp.Header.Set("Location", "/v2/test/blobs/uploads/session")
+	case http.MethodPut:
+		resp.StatusCode = http.StatusCreated
+	}
+	return resp, nil
+}
+
+func (c *replayClient) readSlowly(w io.Writer, r io.Reader) error {
+	buf := make([]byte, 1024)
+	for {
+		time.Sleep(progressInterval)
+		n, err := r.Read(buf)
+		w.Write(buf[:n])
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestUploadChunkedReplay(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 400)
+	path := filepath.Join(t.TempDir(), "blob")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dgst, size, err := digestFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := createRepository("registry.example/test:latest", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &replayClient{}
+	repo.Client = client
+
+	desc := ocispec.Descriptor{MediaType: "application/octet-stream", Digest: dgst, Size: size}
+	var events []Event
+	progress := newProgressReporter(func(e Event) { events = append(events, e) }, "linux/amd64", dgst, size)
+	if _, err := pushBlobFile(context.Background(), repo, path, desc, 2048, progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(client.received.Bytes(), data) {
+		t.Fatalf("registry received %d bytes, want %d", client.received.Len(), len(data))
+	}
+	var last int64
+	for _, e := range events {
+		if e.Type != EventProgress {
+			continue
+		}
+		if e.Current <= last {
+			t.Errorf("progress went from %d to %d", last, e.Current)
+		}
+		last = e.Current
+	}
+	if last != size {
+		t.Errorf("progress ended at %d, want %d", last, size)
+	}
+}
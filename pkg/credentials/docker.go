@@ -0,0 +1,232 @@
+// Package credentials reads registry credentials from the Docker
+// configuration, including credential stores and per-registry helpers.
+package credentials
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Credential holds what is needed to authenticate against a registry
+type Credential struct {
+	// Username for basic or token authentication
+	Username string
+	// Password for basic or token authentication
+	Password string
+	// IdentityToken is an OAuth2 refresh token used instead of a password
+	IdentityToken string
+}
+
+// IsEmpty reports whether the credential has nothing to authenticate with
+func (c Credential) IsEmpty() bool {
+	return c.IdentityToken == "" && (c.Username == "" || c.Password == "")
+}
+
+// Config is the content of a Docker config.json
+type Config struct {
+	Auths       map[string]AuthConfig `json:"auths,omitempty"`
+	CredsStore  string                `json:"credsStore,omitempty"`
+	CredHelpers map[string]string     `json:"credHelpers,omitempty"`
+}
+
+// AuthConfig is an entry of the auths section of a Docker config.json
+type AuthConfig struct {
+	Auth          string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// ConfigDir returns the Docker configuration directory. It honours the
+// DOCKER_CONFIG environment variable and defaults to ~/.docker.
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".docker"), nil
+}
+
+// LoadConfig reads config.json from the Docker configuration directory.
+// A missing file results in an empty configuration.
+func LoadConfig() (*Config, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadConfigFile(filepath.Join(dir, "config.json"))
+}
+
+// LoadConfigFile reads a Docker config.json from path.
+// A missing file results in an empty configuration.
+func LoadConfigFile(path string) (*Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &config, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Get returns credentials for registry from the Docker configuration.
+// It returns an empty Credential if none are configured.
+func Get(registry string) (Credential, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return Credential{}, err
+	}
+
+	return config.Get(registry)
+}
+
+// Get returns credentials for registry. A helper configured for the
+// registry in credHelpers takes precedence over the credsStore, which
+// takes precedence over entries in auths.
+func (c *Config) Get(registry string) (Credential, error) {
+	if helper := c.HelperFor(registry); helper != nil {
+		cred, err := helper.Get(ServerURL(registry))
+		if err == nil && !cred.IsEmpty() {
+			return cred, nil
+		}
+		if err != nil && !errors.Is(err, ErrCredentialsNotFound) {
+			return Credential{}, err
+		}
+	}
+
+	// Try to find auth for this registry
+	for _, key := range registryKeys(registry) {
+		authConfig, ok := c.Auths[key]
+		if !ok {
+			continue
+		}
+		if cred := authConfig.credential(); !cred.IsEmpty() {
+			return cred, nil
+		}
+	}
+
+	return Credential{}, nil
+}
+
+// HelperFor returns the credential helper responsible for registry,
+// or nil if credentials are stored in the config file itself
+func (c *Config) HelperFor(registry string) *Helper {
+	for _, key := range registryKeys(registry) {
+		if name, ok := c.CredHelpers[key]; ok && name != "" {
+			return NewHelper(name)
+		}
+	}
+
+	if c.CredsStore != "" {
+		return NewHelper(c.CredsStore)
+	}
+
+	return nil
+}
+
+// Registries returns the registries that have an entry in the config
+func (c *Config) Registries() []string {
+	seen := make(map[string]bool)
+	var registries []string
+	for reg := range c.Auths {
+		if !seen[reg] {
+			seen[reg] = true
+			registries = append(registries, reg)
+		}
+	}
+	for reg := range c.CredHelpers {
+		if !seen[reg] {
+			seen[reg] = true
+			registries = append(registries, reg)
+		}
+	}
+	return registries
+}
+
+func (a AuthConfig) credential() Credential {
+	cred := Credential{
+		Username:      a.Username,
+		Password:      a.Password,
+		IdentityToken: a.IdentityToken,
+	}
+
+	if a.Auth != "" {
+		// Decode base64 auth string
+		decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+		if err == nil {
+			// Auth string is in format "username:password"
+			if username, password, ok := strings.Cut(string(decoded), ":"); ok {
+				cred.Username = username
+				cred.Password = password
+			}
+		}
+	}
+
+	// With an identity token the username is informational only
+	if cred.IdentityToken != "" {
+		cred.Password = ""
+	}
+
+	return cred
+}
+
+// ServerURL returns the key Docker uses to store credentials for registry
+func ServerURL(registry string) string {
+	normalized := normalizeRegistry(registry)
+	if normalized == "https://index.docker.io/v1/" {
+		return normalized
+	}
+	return strings.TrimSuffix(normalized, "/")
+}
+
+func registryKeys(registry string) []string {
+	// Try multiple registry key formats
+	keys := []string{
+		registry,
+		normalizeRegistry(registry),
+		"https://" + registry,
+		"http://" + registry,
+		strings.TrimPrefix(registry, "docker.io/"),
+	}
+
+	// Add Docker Hub specific variants
+	if strings.Contains(registry, "docker.io") || registry == "docker.io" {
+		keys = append(keys,
+			"https://index.docker.io/v1/",
+			"index.docker.io",
+			"docker.io",
+		)
+	}
+
+	return keys
+}
+
+func normalizeRegistry(registry string) string {
+	// Remove protocol if present
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+
+	// Docker Hub special cases
+	if registry == "docker.io" || registry == "index.docker.io" || registry == "registry-1.docker.io" {
+		return "https://index.docker.io/v1/"
+	}
+
+	return registry
+}
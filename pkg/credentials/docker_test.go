@@ -0,0 +1,80 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeFakeHelper installs a docker-credential-<name> script on PATH that
+// answers "get" for one server URL
+func writeFakeHelper(t *testing.T, name, serverURL, response string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake helper is a shell script")
+	}
+
+	dir := t.TempDir()
+	script := `#!/bin/sh
+read server
+if [ "$1" = "get" ] && [ "$server" = "` + serverURL + `" ]; then
+  echo '` + response + `'
+  exit 0
+fi
+echo "credentials not found in native keychain"
+exit 1
+`
+	if err := os.WriteFile(filepath.Join(dir, "docker-credential-"+name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func writeConfig(t *testing.T, content string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG", dir)
+}
+
+func TestGet(t *testing.T) {
+	writeFakeHelper(t, "fake", "ghcr.io", `{"ServerURL":"ghcr.io","Username":"helper-user","Secret":"helper-pass"}`)
+	writeFakeHelper(t, "tokens", "registry.example.com", `{"ServerURL":"registry.example.com","Username":"<token>","Secret":"refresh"}`)
+	writeConfig(t, `{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "aHViLXVzZXI6aHViLXBhc3M="},
+			"quay.io": {"identitytoken": "quay-token"},
+			"other.io": {}
+		},
+		"credsStore": "fake",
+		"credHelpers": {"registry.example.com": "tokens"}
+	}`)
+
+	tests := []struct {
+		registry string
+		want     Credential
+	}{
+		{"ghcr.io", Credential{Username: "helper-user", Password: "helper-pass"}},
+		{"registry.example.com", Credential{IdentityToken: "refresh"}},
+		{"docker.io", Credential{Username: "hub-user", Password: "hub-pass"}},
+		{"quay.io", Credential{IdentityToken: "quay-token"}},
+		{"other.io", Credential{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			got, err := Get(tt.registry)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
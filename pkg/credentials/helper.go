@@ -0,0 +1,111 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// ErrCredentialsNotFound is returned by a credential helper that has no
+// credentials for the requested server
+var ErrCredentialsNotFound = errors.New("credentials not found in native keychain")
+
+// tokenUsername is the username credential helpers use to mark the secret
+// as an identity token rather than a password
+const tokenUsername = "<token>"
+
+// Helper talks to a docker-credential-* helper program such as
+// docker-credential-desktop or docker-credential-pass
+type Helper struct {
+	// Name of the helper without the "docker-credential-" prefix
+	Name string
+}
+
+// NewHelper returns a Helper for the program docker-credential-<name>
+func NewHelper(name string) *Helper {
+	return &Helper{Name: name}
+}
+
+type helperCredential struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// Get returns the credentials stored for serverURL
+func (h *Helper) Get(serverURL string) (Credential, error) {
+	out, err := h.execute("get", strings.NewReader(serverURL))
+	if err != nil {
+		return Credential{}, err
+	}
+
+	var hc helperCredential
+	if err := json.Unmarshal(out, &hc); err != nil {
+		return Credential{}, fmt.Errorf("docker-credential-%s: invalid response: %w", h.Name, err)
+	}
+
+	if hc.Username == tokenUsername {
+		return Credential{IdentityToken: hc.Secret}, nil
+	}
+
+	return Credential{Username: hc.Username, Password: hc.Secret}, nil
+}
+
+// Store saves credentials for serverURL
+func (h *Helper) Store(serverURL string, cred Credential) error {
+	hc := helperCredential{
+		ServerURL: serverURL,
+		Username:  cred.Username,
+		Secret:    cred.Password,
+	}
+	if cred.IdentityToken != "" {
+		hc.Username = tokenUsername
+		hc.Secret = cred.IdentityToken
+	}
+
+	data, err := json.Marshal(hc)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.execute("store", bytes.NewReader(data))
+	return err
+}
+
+// Erase removes the credentials stored for serverURL
+func (h *Helper) Erase(serverURL string) error {
+	_, err := h.execute("erase", strings.NewReader(serverURL))
+	return err
+}
+
+func (h *Helper) execute(action string, input io.Reader) ([]byte, error) {
+	program := "docker-credential-" + h.Name
+
+	cmd := exec.Command(program, action)
+	cmd.Stdin = input
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// Helpers report errors on stdout
+		msg := strings.TrimSpace(stdout.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stderr.String())
+		}
+		if msg == ErrCredentialsNotFound.Error() {
+			return nil, ErrCredentialsNotFound
+		}
+		if msg != "" {
+			return nil, fmt.Errorf("%s %s: %s", program, action, msg)
+		}
+		return nil, fmt.Errorf("%s %s: %w", program, action, err)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,171 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotLoggedIn is returned by Erase when no credentials are stored for
+// the registry
+var ErrNotLoggedIn = errors.New("not logged in")
+
+// StorePath returns the path of the bolter credentials file,
+// ~/.config/bolter/credentials.json on Linux
+func StorePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, "bolter", "credentials.json"), nil
+}
+
+// Lookup returns credentials for registry, preferring those saved by Store
+// over the Docker configuration. It returns an empty Credential if none
+// are found.
+func Lookup(registry string) (Credential, error) {
+	path, err := StorePath()
+	if err == nil {
+		store, err := LoadConfigFile(path)
+		if err != nil {
+			return Credential{}, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if cred, err := store.Get(registry); err == nil && !cred.IsEmpty() {
+			return cred, nil
+		}
+	}
+
+	return Get(registry)
+}
+
+// Store saves credentials for registry. They go to the credential helper
+// configured for the registry in the Docker configuration if there is one,
+// otherwise into the bolter credentials file, which is only readable by the
+// current user. It returns a description of where they were stored.
+func Store(registry string, cred Credential) (string, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return "", err
+	}
+
+	if helper := config.HelperFor(registry); helper != nil {
+		if err := helper.Store(ServerURL(registry), cred); err != nil {
+			return "", err
+		}
+		return "docker-credential-" + helper.Name, nil
+	}
+
+	path, err := StorePath()
+	if err != nil {
+		return "", err
+	}
+
+	store, err := LoadConfigFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	if store.Auths == nil {
+		store.Auths = make(map[string]AuthConfig)
+	}
+	for _, key := range registryKeys(registry) {
+		delete(store.Auths, key)
+	}
+
+	entry := AuthConfig{IdentityToken: cred.IdentityToken}
+	if cred.Username != "" || cred.Password != "" {
+		entry.Auth = base64.StdEncoding.EncodeToString([]byte(cred.Username + ":" + cred.Password))
+	}
+	store.Auths[ServerURL(registry)] = entry
+
+	if err := saveStore(path, store); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Erase removes credentials for registry from the bolter credentials file
+// and from the credential helper configured for the registry.
+func Erase(registry string) error {
+	found := false
+
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	if helper := config.HelperFor(registry); helper != nil {
+		err := helper.Erase(ServerURL(registry))
+		if err == nil {
+			found = true
+		} else if !errors.Is(err, ErrCredentialsNotFound) {
+			return err
+		}
+	}
+
+	path, err := StorePath()
+	if err != nil {
+		return err
+	}
+
+	store, err := LoadConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range registryKeys(registry) {
+		if _, ok := store.Auths[key]; ok {
+			delete(store.Auths, key)
+			found = true
+		}
+	}
+
+	if err := saveStore(path, store); err != nil {
+		return err
+	}
+
+	if !found {
+		return ErrNotLoggedIn
+	}
+
+	return nil
+}
+
+func saveStore(path string, store *Config) error {
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".credentials.*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp already uses 0600, but make sure of it before writing secrets
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
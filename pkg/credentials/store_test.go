@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestStoreAndErase(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	writeConfig(t, `{"auths": {"ghcr.io": {"auth": "ZG9ja2VyOmRvY2tlcg=="}}}`)
+
+	cred := Credential{Username: "me", Password: "secret"}
+	path, err := Store("ghcr.io", cred)
+	if err != nil {
+		t.Fatalf("failed to store: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("credentials file has mode %o, want 600", perm)
+	}
+
+	// Stored credentials take precedence over the Docker config
+	got, err := Lookup("ghcr.io")
+	if err != nil || got != cred {
+		t.Fatalf("Lookup() = %+v, %v; want %+v", got, err, cred)
+	}
+
+	if err := Erase("ghcr.io"); err != nil {
+		t.Fatalf("failed to erase: %v", err)
+	}
+
+	got, err = Lookup("ghcr.io")
+	if err != nil || got.Username != "docker" {
+		t.Fatalf("expected fallback to Docker config after erase, got %+v, %v", got, err)
+	}
+
+	if err := Erase("ghcr.io"); !errors.Is(err, ErrNotLoggedIn) {
+		t.Fatalf("expected ErrNotLoggedIn, got %v", err)
+	}
+}